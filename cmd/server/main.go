@@ -1,58 +1,118 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/joho/godotenv"
+	"github.com/pollz/websocket-server/internal/auth"
 	"github.com/pollz/websocket-server/internal/config"
 	"github.com/pollz/websocket-server/internal/database"
 	"github.com/pollz/websocket-server/internal/handlers"
 	"github.com/pollz/websocket-server/internal/hub"
+	"github.com/pollz/websocket-server/internal/logging"
 	"github.com/pollz/websocket-server/internal/redis"
 	"github.com/pollz/websocket-server/internal/server"
 )
 
 func main() {
 	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found")
-	}
+	envErr := godotenv.Load()
 
 	// Load configuration
 	cfg := config.Load()
+	logging.Setup(os.Stdout, logging.Options{
+		Level:      cfg.LogLevel,
+		Format:     cfg.LogFormat,
+		LogContent: cfg.LogChatContent,
+	})
+	if envErr != nil {
+		slog.Info("no .env file found")
+	}
+	if cfg.LogChatContent {
+		slog.Warn("chat content and client IPs are written to the logs")
+	}
 
 	// Initialize database
 	db, err := database.Connect(cfg.DatabaseURL)
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		fatal("failed to connect to database", err)
 	}
 	defer db.Close()
 
 	// Run migrations
 	if err := database.Migrate(db); err != nil {
-		log.Fatal("Failed to run migrations:", err)
+		fatal("failed to run migrations", err)
 	}
 
 	// Initialize Redis
 	redisClient, err := redis.Connect(cfg.RedisURL)
 	if err != nil {
-		log.Fatal("Failed to connect to Redis:", err)
+		fatal("failed to connect to Redis", err)
 	}
 	defer redisClient.Close()
 
 	// Create message hub
-	messageHub := hub.New(redisClient, db)
+	messageHub := hub.New(cfg, redisClient, db)
 	go messageHub.Run()
 
 	// Create handlers
-	wsHandler := handlers.NewWebSocketHandler(messageHub)
+	if cfg.JWTSecret == "" {
+		slog.Warn("JWT_SECRET is not set; all tokens will be rejected")
+	} else if err := auth.CheckSecret(cfg.JWTSecret); err != nil {
+		fatal("insecure JWT_SECRET", err)
+	}
+	verifier := auth.NewVerifier(cfg.JWTSecret)
+	proxies, err := handlers.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		fatal("invalid TRUSTED_PROXIES", err)
+	}
+	wsHandler := handlers.NewWebSocketHandler(messageHub, verifier, cfg.AllowAnonymous, cfg.WSCompression, proxies)
 	apiHandler := handlers.NewAPIHandler(messageHub)
+	adminHandler := handlers.NewAdminHandler(messageHub, cfg.AdminAPIKey, verifier)
+	internalHandler := handlers.NewInternalHandler(messageHub, cfg.InternalAPIKey, cfg.InternalSigningSecret)
 
 	// Start server
-	srv := server.New(cfg, wsHandler, apiHandler)
+	srv := server.New(cfg, wsHandler, apiHandler, adminHandler, internalHandler)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	log.Printf("Starting WebSocket server on port %s", cfg.Port)
-	if err := srv.Start(); err != nil {
-		log.Fatal("Server failed to start:", err)
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("starting WebSocket server", "port", cfg.Port)
+		serverErr <- srv.Start()
+	}()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			fatal("server failed to start", err)
+		}
+	case <-ctx.Done():
+	}
+
+	// Drain: stop accepting connections, disconnect clients with a
+	// reconnect hint and flush pending writes before the pools close
+	slog.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("error shutting down HTTP server", "error", err)
 	}
+	if err := messageHub.Shutdown(shutdownCtx); err != nil {
+		slog.Error("error shutting down hub", "error", err)
+	}
+	slog.Info("shutdown complete")
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidToken is returned for malformed tokens, unsupported
+	// algorithms and bad signatures
+	ErrInvalidToken = errors.New("invalid token")
+
+	// ErrTokenExpired is returned for correctly signed tokens past their exp
+	ErrTokenExpired = errors.New("token expired")
+)
+
+// clockSkew is the leeway allowed when checking exp and nbf
+const clockSkew = 30 * time.Second
+
+// MinSecretLength is the shortest secret accepted for signing tokens, the
+// size of an HS256 key
+const MinSecretLength = 32
+
+// placeholderSecrets are values copied from examples and tutorials
+var placeholderSecrets = map[string]bool{
+	"change-me":   true,
+	"changeme":    true,
+	"secret":      true,
+	"your-secret": true,
+	"jwt-secret":  true,
+	"development": true,
+	// long enough to pass the length check
+	"please-change-this-to-a-random-secret": true,
+}
+
+// CheckSecret rejects secrets that are known placeholders or too short to
+// resist brute force. An empty secret is left to the caller, as it rejects
+// every token.
+func CheckSecret(secret string) error {
+	if placeholderSecrets[strings.ToLower(secret)] {
+		return errors.New("secret is a placeholder value")
+	}
+	if len(secret) < MinSecretLength {
+		return fmt.Errorf("secret is %d bytes, need at least %d", len(secret), MinSecretLength)
+	}
+	return nil
+}
+
+// Claims are the verified fields of an access token issued by the Pollz
+// backend.
+type Claims struct {
+	UserID    string
+	Username  string
+	Role      string
+	ExpiresAt time.Time
+}
+
+// Verifier checks HS256-signed JWTs against a secret shared with the
+// backend.
+type Verifier struct {
+	secret []byte
+	now    func() time.Time
+}
+
+func NewVerifier(secret string) *Verifier {
+	return &Verifier{
+		secret: []byte(secret),
+		now:    time.Now,
+	}
+}
+
+// Verify checks the token's signature and expiry and returns its claims.
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	if len(v.secret) == 0 {
+		return nil, fmt.Errorf("%w: no secret configured", ErrInvalidToken)
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Alg != "HS256" {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidToken)
+	}
+	mac := hmac.New(sha256.New, v.secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	var payload map[string]interface{}
+	if err := decodeSegment(parts[1], &payload); err != nil {
+		return nil, err
+	}
+
+	now := v.now()
+	exp, ok := numericClaim(payload, "exp")
+	if !ok {
+		return nil, fmt.Errorf("%w: missing exp", ErrInvalidToken)
+	}
+	expiresAt := time.Unix(exp, 0)
+	if now.After(expiresAt.Add(clockSkew)) {
+		return nil, ErrTokenExpired
+	}
+	if nbf, ok := numericClaim(payload, "nbf"); ok && now.Add(clockSkew).Before(time.Unix(nbf, 0)) {
+		return nil, fmt.Errorf("%w: token not valid yet", ErrInvalidToken)
+	}
+
+	// Refresh tokens from the backend must not be used to open connections
+	if tokenType := stringClaim(payload, "token_type"); tokenType != "" && tokenType != "access" {
+		return nil, fmt.Errorf("%w: unexpected token type %q", ErrInvalidToken, tokenType)
+	}
+
+	userID := stringClaim(payload, "user_id")
+	if userID == "" {
+		userID = stringClaim(payload, "sub")
+	}
+	if userID == "" {
+		return nil, fmt.Errorf("%w: missing user_id", ErrInvalidToken)
+	}
+
+	username := stringClaim(payload, "username")
+	if username == "" {
+		username = stringClaim(payload, "name")
+	}
+
+	return &Claims{
+		UserID:    userID,
+		Username:  username,
+		Role:      stringClaim(payload, "role"),
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("%w: malformed segment", ErrInvalidToken)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%w: malformed segment", ErrInvalidToken)
+	}
+	return nil
+}
+
+// stringClaim returns a string or numeric claim as a string. The backend
+// issues numeric user IDs.
+func stringClaim(payload map[string]interface{}, key string) string {
+	switch v := payload[key].(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	return ""
+}
+
+func numericClaim(payload map[string]interface{}, key string) (int64, bool) {
+	n, ok := payload[key].(json.Number)
+	if !ok {
+		return 0, false
+	}
+	if i, err := n.Int64(); err == nil {
+		return i, true
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return 0, false
+	}
+	return int64(f), true
+}
@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// sign builds a token with the given header and payload, signed with secret
+func sign(t *testing.T, secret string, header, payload map[string]interface{}) string {
+	t.Helper()
+
+	encode := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	unsigned := encode(header) + "." + encode(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	hs256 := map[string]interface{}{"alg": "HS256", "typ": "JWT"}
+	claims := func(extra map[string]interface{}) map[string]interface{} {
+		payload := map[string]interface{}{
+			"user_id":  42,
+			"username": "asha",
+			"exp":      now.Add(time.Hour).Unix(),
+		}
+		for k, v := range extra {
+			payload[k] = v
+		}
+		return payload
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		// want is nil for a token that verifies
+		want error
+	}{
+		{name: "valid", token: sign(t, testSecret, hs256, claims(nil))},
+		{
+			name:  "alg none",
+			token: sign(t, testSecret, map[string]interface{}{"alg": "none"}, claims(nil)),
+			want:  ErrInvalidToken,
+		},
+		{
+			name: "alg none without signature",
+			token: func() string {
+				parts := strings.Split(sign(t, testSecret, map[string]interface{}{"alg": "none"}, claims(nil)), ".")
+				return parts[0] + "." + parts[1] + "."
+			}(),
+			want: ErrInvalidToken,
+		},
+		{
+			name:  "alg RS256",
+			token: sign(t, testSecret, map[string]interface{}{"alg": "RS256"}, claims(nil)),
+			want:  ErrInvalidToken,
+		},
+		{
+			name:  "alg in lower case",
+			token: sign(t, testSecret, map[string]interface{}{"alg": "hs256"}, claims(nil)),
+			want:  ErrInvalidToken,
+		},
+		{
+			name:  "wrong secret",
+			token: sign(t, "fedcba9876543210fedcba9876543210", hs256, claims(nil)),
+			want:  ErrInvalidToken,
+		},
+		{
+			name: "tampered payload",
+			token: func() string {
+				parts := strings.Split(sign(t, testSecret, hs256, claims(nil)), ".")
+				forged := strings.Split(sign(t, testSecret, hs256, claims(map[string]interface{}{"role": "admin"})), ".")
+				return parts[0] + "." + forged[1] + "." + parts[2]
+			}(),
+			want: ErrInvalidToken,
+		},
+		{
+			name:  "expired",
+			token: sign(t, testSecret, hs256, claims(map[string]interface{}{"exp": now.Add(-time.Minute).Unix()})),
+			want:  ErrTokenExpired,
+		},
+		{
+			name:  "expired within clock skew",
+			token: sign(t, testSecret, hs256, claims(map[string]interface{}{"exp": now.Add(-clockSkew / 2).Unix()})),
+		},
+		{
+			name:  "missing exp",
+			token: sign(t, testSecret, hs256, map[string]interface{}{"user_id": 42}),
+			want:  ErrInvalidToken,
+		},
+		{
+			name:  "not valid yet",
+			token: sign(t, testSecret, hs256, claims(map[string]interface{}{"nbf": now.Add(time.Minute).Unix()})),
+			want:  ErrInvalidToken,
+		},
+		{
+			name:  "nbf within clock skew",
+			token: sign(t, testSecret, hs256, claims(map[string]interface{}{"nbf": now.Add(clockSkew / 2).Unix()})),
+		},
+		{
+			name:  "refresh token",
+			token: sign(t, testSecret, hs256, claims(map[string]interface{}{"token_type": "refresh"})),
+			want:  ErrInvalidToken,
+		},
+		{name: "malformed", token: "not.a-token", want: ErrInvalidToken},
+	}
+
+	v := NewVerifier(testSecret)
+	v.now = func() time.Time { return now }
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := v.Verify(tc.token)
+			if tc.want == nil {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				if got.UserID != "42" || got.Username != "asha" {
+					t.Errorf("claims = %+v, want user 42 asha", got)
+				}
+				return
+			}
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("Verify error = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestVerifyWithoutSecret(t *testing.T) {
+	token := sign(t, "", map[string]interface{}{"alg": "HS256"}, map[string]interface{}{
+		"user_id": 42,
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	})
+	if _, err := NewVerifier("").Verify(token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Verify error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestCheckSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  string
+		wantErr bool
+	}{
+		{name: "long random", secret: testSecret},
+		{name: "placeholder", secret: "change-me", wantErr: true},
+		{name: "placeholder in upper case", secret: "CHANGEME", wantErr: true},
+		{name: "long placeholder", secret: "please-change-this-to-a-random-secret", wantErr: true},
+		{name: "too short", secret: strings.Repeat("x", MinSecretLength-1), wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := CheckSecret(tc.secret); (err != nil) != tc.wantErr {
+				t.Errorf("CheckSecret(%q) = %v, want error %v", tc.secret, err, tc.wantErr)
+			}
+		})
+	}
+}
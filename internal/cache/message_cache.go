@@ -10,86 +10,176 @@ import (
 )
 
 type MessageCache struct {
-	client *redis.Client
-	key    string
-	maxLen int64
+	client        *redis.Client
+	keyPrefix     string
+	clearedPrefix string
+	maxLen        int64
 }
 
 func NewMessageCache(client *redis.Client) *MessageCache {
 	return &MessageCache{
-		client: client,
-		key:    "chat_messages",
-		maxLen: 100,
+		client:        client,
+		keyPrefix:     "chat_messages:",
+		clearedPrefix: "chat_cleared:",
+		maxLen:        100,
 	}
 }
 
-func (c *MessageCache) Push(msg models.Message) error {
-	ctx := context.Background()
-	
+// key returns the Redis list holding the recent messages of a room
+func (c *MessageCache) key(room string) string {
+	return c.keyPrefix + room
+}
+
+// clearedKey returns the Redis key holding the latest sequence number
+// cleared in a room
+func (c *MessageCache) clearedKey(room string) string {
+	return c.clearedPrefix + room
+}
+
+// PushPipe queues pushing a message to its room's list on pipe, so a batch
+// of messages is cached in order in one round trip
+func (c *MessageCache) PushPipe(ctx context.Context, pipe redis.Pipeliner, msg models.Message) error {
 	data, err := json.Marshal(msg)
 	if err != nil {
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
-	
-	// Push to Redis list
-	if err := c.client.LPush(ctx, c.key, data).Err(); err != nil {
-		return fmt.Errorf("failed to push message to cache: %w", err)
-	}
-	
-	// Trim to maintain max length
-	if err := c.client.LTrim(ctx, c.key, 0, c.maxLen-1).Err(); err != nil {
-		return fmt.Errorf("failed to trim cache: %w", err)
-	}
-	
+
+	// Push to the room's Redis list, trimmed to its max length
+	key := c.key(msg.Room)
+	pipe.LPush(ctx, key, data)
+	pipe.LTrim(ctx, key, 0, c.maxLen-1)
 	return nil
 }
 
-func (c *MessageCache) GetRecent(limit int64) ([]models.Message, error) {
+func (c *MessageCache) GetRecent(room string, limit int64) ([]models.Message, error) {
 	ctx := context.Background()
-	
+
 	if limit > c.maxLen {
 		limit = c.maxLen
 	}
-	
-	data, err := c.client.LRange(ctx, c.key, 0, limit-1).Result()
-	if err != nil {
+
+	pipe := c.client.Pipeline()
+	list := pipe.LRange(ctx, c.key(room), 0, limit-1)
+	clearedCmd := pipe.Get(ctx, c.clearedKey(room))
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
 		return nil, fmt.Errorf("failed to get messages from cache: %w", err)
 	}
-	
+	data := list.Val()
+	cleared, _ := clearedCmd.Int64()
+
 	messages := make([]models.Message, 0, len(data))
 	for i := len(data) - 1; i >= 0; i-- {
 		var msg models.Message
 		if err := json.Unmarshal([]byte(data[i]), &msg); err != nil {
 			continue
 		}
+		// Skip messages pushed after the room was cleared that were sent
+		// before it was. Messages without a sequence number predate
+		// sequencing and are kept.
+		if msg.Seq > 0 && msg.Seq <= cleared {
+			continue
+		}
 		messages = append(messages, msg)
 	}
-	
+
 	return messages, nil
 }
 
-func (c *MessageCache) Clear() error {
-	ctx := context.Background()
-	return c.client.Del(ctx, c.key).Err()
+// Find looks a message up among the room's cached recent messages
+func (c *MessageCache) Find(room, id string) (models.Message, bool, error) {
+	messages, err := c.GetRecent(room, c.maxLen)
+	if err != nil {
+		return models.Message{}, false, err
+	}
+	for _, msg := range messages {
+		if msg.ID == id {
+			return msg, true, nil
+		}
+	}
+	return models.Message{}, false, nil
 }
 
-func (c *MessageCache) Populate(messages []models.Message) error {
+// replaceItem swaps one element of a list for another in place, so
+// messages pushed meanwhile do not shift the wrong element
+var replaceItem = redis.NewScript(`
+local i = redis.call('LPOS', KEYS[1], ARGV[1])
+if i then
+	redis.call('LSET', KEYS[1], i, ARGV[2])
+end
+return i
+`)
+
+// Remove deletes a message from the room's cached recent messages and
+// drops the previews of it from cached replies, as the database does for
+// replies to deleted messages
+func (c *MessageCache) Remove(room, id string) error {
 	ctx := context.Background()
-	
-	// Clear existing cache
-	if err := c.Clear(); err != nil {
-		return err
+	key := c.key(room)
+
+	data, err := c.client.LRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get messages from cache: %w", err)
 	}
-	
-	// Add messages to cache
+
+	for _, raw := range data {
+		var msg models.Message
+		if err := json.Unmarshal([]byte(raw), &msg); err != nil {
+			continue
+		}
+		switch {
+		case msg.ID == id:
+			if err := c.client.LRem(ctx, key, 0, raw).Err(); err != nil {
+				return fmt.Errorf("failed to remove message from cache: %w", err)
+			}
+		case msg.ReplyTo == id && msg.ReplyPreview != nil:
+			msg.ReplyPreview = nil
+			reply, err := json.Marshal(msg)
+			if err != nil {
+				return fmt.Errorf("failed to marshal message: %w", err)
+			}
+			if err := replaceItem.Run(ctx, c.client, []string{key}, raw, reply).Err(); err != nil && err != redis.Nil {
+				return fmt.Errorf("failed to update cached reply: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *MessageCache) Clear(room string) error {
+	ctx := context.Background()
+	return c.client.Del(ctx, c.key(room)).Err()
+}
+
+// ClearUpTo empties the room's cached messages and records seq as the
+// latest sequence number cleared, so messages up to it that are still
+// being pushed are never returned
+func (c *MessageCache) ClearUpTo(room string, seq int64) error {
+	ctx := context.Background()
+	pipe := c.client.TxPipeline()
+	pipe.Set(ctx, c.clearedKey(room), seq, 0)
+	pipe.Del(ctx, c.key(room))
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Populate replaces the room's cached messages with messages, oldest
+// first. They are pushed to the head of the list like PushPipe does, so
+// the newest ends up first.
+func (c *MessageCache) Populate(room string, messages []models.Message) error {
+	ctx := context.Background()
+	key := c.key(room)
+
+	pipe := c.client.TxPipeline()
+	pipe.Del(ctx, key)
 	for _, msg := range messages {
 		data, err := json.Marshal(msg)
 		if err != nil {
 			continue
 		}
-		c.client.RPush(ctx, c.key, data)
+		pipe.LPush(ctx, key, data)
 	}
-	
-	// Trim to max length
-	return c.client.LTrim(ctx, c.key, 0, c.maxLen-1).Err()
-}
\ No newline at end of file
+	pipe.LTrim(ctx, key, 0, c.maxLen-1)
+	_, err := pipe.Exec(ctx)
+	return err
+}
@@ -4,14 +4,15 @@ import (
 	"database/sql"
 	"fmt"
 	
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 func Connect(databaseURL string) (*sql.DB, error) {
-	db, err := sql.Open("postgres", databaseURL)
+	connector, err := pq.NewConnector(databaseURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	db := sql.OpenDB(instrumentedConnector{connector})
 
 	// Configure connection pool
 	db.SetMaxOpenConns(25)
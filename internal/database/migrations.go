@@ -4,6 +4,29 @@ import (
 	"database/sql"
 )
 
+// defaultProfanityWords seeds the profanity_words table
+const defaultProfanityWords = `
+	'aad', 'aand', 'bahenchod', 'behenchod', 'bhenchod', 'bhenchodd', 'b.c.', 'bc',
+	'bakchod', 'bakchodd', 'bakchodi', 'bevda', 'bewda', 'bevdey', 'bewday', 'bevakoof',
+	'bevkoof', 'bevkuf', 'bewakoof', 'bewkoof', 'bewkuf', 'bhadua', 'bhaduaa', 'bhadva',
+	'bhadvaa', 'bhadwa', 'bhadwaa', 'bhosada', 'bhosda', 'bhosdaa', 'bhosdike', 'bhonsdike',
+	'bsdk', 'b.s.d.k', 'bhosdiki', 'bhosdiwala', 'bhosdiwale', 'bhosadchodal', 'bhosadchod',
+	'babbe', 'babbey', 'bube', 'bubey', 'bur', 'burr', 'buurr', 'buur', 'charsi', 'chooche',
+	'choochi', 'chuchi', 'chhod', 'chod', 'chodd', 'chudne', 'chudney', 'chudwa', 'chudwaa',
+	'chudwane', 'chudwaane', 'choot', 'chut', 'chute', 'chutia', 'chutiya', 'chutiye',
+	'chuttad', 'chutad', 'dalaal', 'dalal', 'dalle', 'dalley', 'fattu', 'gadha', 'gadhe',
+	'gadhalund', 'gaand', 'gand', 'gandu', 'gandfat', 'gandfut', 'gandiya', 'gandiye', 'goo',
+	'gu', 'gote', 'gotey', 'gotte', 'hag', 'haggu', 'hagne', 'hagney', 'harami', 'haramjada',
+	'haraamjaada', 'haramzyada', 'haraamzyaada', 'haraamjaade', 'haraamzaade', 'haraamkhor',
+	'haramkhor', 'jhat', 'jhaat', 'jhaatu', 'jhatu', 'kutta', 'kutte', 'kuttey', 'kutia',
+	'kutiya', 'kuttiya', 'kutti', 'landi', 'landy', 'laude', 'laudey', 'laura', 'lora',
+	'lauda', 'ling', 'loda', 'lode', 'lund', 'launda', 'lounde', 'laundey', 'laundi', 'loundi',
+	'laundiya', 'loundiya', 'lulli', 'maar', 'maro', 'marunga', 'madarchod', 'madarchodd',
+	'madarchood', 'madarchoot', 'madarchut', 'm.c.', 'mc', 'mamme', 'mammey', 'moot', 'mut',
+	'mootne', 'mutne', 'mooth', 'muth', 'nunni', 'nunnu', 'paaji', 'paji', 'pesaab', 'pesab',
+	'peshaab', 'peshab', 'pilla', 'pillay', 'pille', 'pilley', 'pisaab', 'pisab', 'pkmkb',
+	'porkistan', 'raand', 'rand', 'randi', 'randy', 'suar', 'tatte', 'tatti', 'tatty', 'ullu'`
+
 func Migrate(db *sql.DB) error {
 	migrations := []string{
 		`CREATE TABLE IF NOT EXISTS chat_messages (
@@ -17,6 +40,119 @@ func Migrate(db *sql.DB) error {
 		`CREATE INDEX IF NOT EXISTS idx_messages_created_at ON chat_messages(created_at DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_messages_user_id ON chat_messages(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_messages_type ON chat_messages(type)`,
+		`ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS room VARCHAR(64) NOT NULL DEFAULT 'live'`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_room_created_at ON chat_messages(room, created_at DESC)`,
+		`ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS reply_to VARCHAR(36)`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_room_created_at_id ON chat_messages(room, created_at DESC, id DESC)`,
+		`ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS seq BIGINT`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_room_seq ON chat_messages(room, seq) WHERE seq IS NOT NULL`,
+		// Full-text search; the simple configuration does not stem, which
+		// suits chat mixing English and Hindi
+		`ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (to_tsvector('simple', content)) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_search_vector ON chat_messages USING GIN (search_vector)`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_reply_to ON chat_messages(reply_to, created_at) WHERE reply_to IS NOT NULL`,
+		`ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP`,
+		`ALTER TABLE chat_messages ADD COLUMN IF NOT EXISTS deleted_by VARCHAR(100)`,
+		// The latest sequence number cleared in each room, so messages that
+		// were still being written when it was cleared are stored deleted
+		`CREATE TABLE IF NOT EXISTS room_clears (
+			room VARCHAR(64) PRIMARY KEY,
+			seq BIGINT NOT NULL,
+			cleared_at TIMESTAMP NOT NULL,
+			cleared_by VARCHAR(100) NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS profanity_words (
+			id SERIAL PRIMARY KEY,
+			word VARCHAR(100) NOT NULL,
+			match_mode VARCHAR(16) NOT NULL DEFAULT 'exact',
+			room VARCHAR(64) NOT NULL DEFAULT '',
+			is_allowed BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (word, match_mode, room, is_allowed)
+		)`,
+		// Reactions reference messages by ID only: messages are written
+		// behind, so a reaction can reach the database first
+		`CREATE TABLE IF NOT EXISTS message_reactions (
+			message_id VARCHAR(36) NOT NULL,
+			user_id VARCHAR(100) NOT NULL,
+			emoji VARCHAR(32) NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (message_id, user_id, emoji)
+		)`,
+		`CREATE TABLE IF NOT EXISTS chat_bans (
+			id SERIAL PRIMARY KEY,
+			user_id VARCHAR(100) NOT NULL DEFAULT '',
+			ip VARCHAR(64) NOT NULL DEFAULT '',
+			reason TEXT NOT NULL DEFAULT '',
+			created_by VARCHAR(100) NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMP,
+			lifted_at TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS moderation_log (
+			id SERIAL PRIMARY KEY,
+			action VARCHAR(20) NOT NULL,
+			moderator_id VARCHAR(100) NOT NULL,
+			user_id VARCHAR(100) NOT NULL DEFAULT '',
+			ip VARCHAR(64) NOT NULL DEFAULT '',
+			message_id VARCHAR(36) NOT NULL DEFAULT '',
+			room VARCHAR(64) NOT NULL DEFAULT '',
+			duration_seconds INTEGER NOT NULL DEFAULT 0,
+			reason TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_moderation_log_created_at ON moderation_log(created_at DESC)`,
+		`CREATE TABLE IF NOT EXISTS superchats (
+			message_id VARCHAR(36) PRIMARY KEY,
+			payment_id VARCHAR(100) NOT NULL UNIQUE,
+			room VARCHAR(64) NOT NULL,
+			user_id VARCHAR(100) NOT NULL,
+			username VARCHAR(100) NOT NULL DEFAULT '',
+			amount BIGINT NOT NULL,
+			currency VARCHAR(3) NOT NULL,
+			tier INTEGER NOT NULL,
+			pinned_until TIMESTAMP NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_superchats_room_user ON superchats(room, user_id)`,
+		`CREATE TABLE IF NOT EXISTS sticker_packs (
+			id VARCHAR(64) PRIMARY KEY,
+			name VARCHAR(100) NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			room VARCHAR(64) NOT NULL DEFAULT '',
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			sort_order INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS stickers (
+			id VARCHAR(64) PRIMARY KEY,
+			pack_id VARCHAR(64) NOT NULL REFERENCES sticker_packs(id) ON DELETE CASCADE,
+			name VARCHAR(100) NOT NULL,
+			image_url TEXT NOT NULL DEFAULT '',
+			sort_order INTEGER NOT NULL DEFAULT 0
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_stickers_pack_id ON stickers(pack_id)`,
+		// Seed the word list on first start; afterwards it is managed
+		// through the admin API
+		`INSERT INTO profanity_words (word)
+		SELECT unnest(ARRAY[` + defaultProfanityWords + `])
+		WHERE NOT EXISTS (SELECT 1 FROM profanity_words)`,
+		// Seed the default sticker pack on first start
+		`INSERT INTO sticker_packs (id, name, description)
+		SELECT 'pollz', 'Pollz', 'Default stickers'
+		WHERE NOT EXISTS (SELECT 1 FROM sticker_packs)`,
+		`INSERT INTO stickers (id, pack_id, name, image_url, sort_order)
+		SELECT s.id, 'pollz', s.name, '/stickers/pollz/' || s.id || '.webp', s.sort_order
+		FROM (VALUES
+			('pollz_vote', 'Vote', 1), ('pollz_ballot', 'Ballot', 2), ('pollz_clap', 'Clap', 3),
+			('pollz_fire', 'Fire', 4), ('pollz_laugh', 'Laugh', 5), ('pollz_thinking', 'Thinking', 6),
+			('pollz_trophy', 'Trophy', 7), ('pollz_heart', 'Heart', 8), ('pollz_thumbs_up', 'Thumbs up', 9),
+			('pollz_mic_drop', 'Mic drop', 10)
+		) AS s(id, name, sort_order)
+		WHERE NOT EXISTS (SELECT 1 FROM stickers)
+		AND EXISTS (SELECT 1 FROM sticker_packs WHERE id = 'pollz')`,
 	}
 
 	for _, migration := range migrations {
@@ -26,4 +162,4 @@ func Migrate(db *sql.DB) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,353 @@
+package filter
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"unicode"
+
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+// Store loads the word list
+type Store interface {
+	ListRules() ([]models.ProfanityRule, error)
+}
+
+// matcher holds the compiled rules of one scope (global or a single room)
+type matcher struct {
+	exact     *Trie
+	prefix    *Trie
+	substring *Trie
+	allow     map[string]bool
+}
+
+func newMatcher() *matcher {
+	return &matcher{
+		exact:     NewTrie(),
+		prefix:    NewTrie(),
+		substring: NewTrie(),
+		allow:     make(map[string]bool),
+	}
+}
+
+func (m *matcher) add(word string, rule models.ProfanityRule) {
+	if rule.Allow {
+		m.allow[word] = true
+		return
+	}
+	switch rule.Mode {
+	case models.PrefixMatch:
+		m.prefix.Insert(word)
+	case models.SubstringMatch:
+		m.substring.Insert(word)
+	default:
+		m.exact.Insert(word)
+	}
+}
+
+func (m *matcher) match(token string) bool {
+	return m.exact.Search(token) || m.prefix.HasPrefixOf(token) || m.substring.ContainedIn(token)
+}
+
+// scope holds the rules of the global list or of a single room, both as
+// normalized words and with repeated letters collapsed. Words that repeat a
+// letter themselves ("aand") are left out of the collapsed rules, as
+// collapsing them would turn them into ordinary words ("and").
+type scope struct {
+	plain     *matcher
+	collapsed *matcher
+}
+
+func newScope() *scope {
+	return &scope{
+		plain:     newMatcher(),
+		collapsed: newMatcher(),
+	}
+}
+
+func (s *scope) add(rule models.ProfanityRule) {
+	word := normalize(rule.Word)
+	if word == "" {
+		return
+	}
+	s.plain.add(word, rule)
+	if collapsed := collapseRepeats(word); collapsed == word || rule.Allow {
+		s.collapsed.add(collapsed, rule)
+	}
+}
+
+// ruleSet is an immutable snapshot of the compiled word list
+type ruleSet struct {
+	global *scope
+	rooms  map[string]*scope
+}
+
+func compile(rules []models.ProfanityRule) *ruleSet {
+	rs := &ruleSet{
+		global: newScope(),
+		rooms:  make(map[string]*scope),
+	}
+	for _, rule := range rules {
+		if rule.Room == "" {
+			rs.global.add(rule)
+			continue
+		}
+		s, ok := rs.rooms[rule.Room]
+		if !ok {
+			s = newScope()
+			rs.rooms[rule.Room] = s
+		}
+		s.add(rule)
+	}
+	return rs
+}
+
+// blocked reports whether a token must be censored in room. The token is
+// normalized and checked as typed first; the form with repeated letters
+// collapsed is only a fallback for tokens that repeat letters.
+func (rs *ruleSet) blocked(room, token string) bool {
+	plain := normalize(token)
+	if plain == "" {
+		return false
+	}
+	collapsed := collapseRepeats(plain)
+
+	scopes := []*scope{rs.global}
+	if s, ok := rs.rooms[room]; ok {
+		scopes = append(scopes, s)
+	}
+
+	for _, s := range scopes {
+		if s.plain.allow[plain] || s.collapsed.allow[collapsed] {
+			return false
+		}
+	}
+	for _, s := range scopes {
+		if s.plain.match(plain) {
+			return true
+		}
+	}
+	if collapsed == plain {
+		return false
+	}
+	for _, s := range scopes {
+		if s.collapsed.match(collapsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter censors chat content against a word list that can be swapped at
+// runtime without blocking readers.
+type Filter struct {
+	store Store
+	rules atomic.Pointer[ruleSet]
+}
+
+func New(store Store) *Filter {
+	f := &Filter{store: store}
+	f.rules.Store(compile(nil))
+	return f
+}
+
+// Reload loads the word list from the store and atomically replaces the
+// active rules. On error the previous rules stay in effect.
+func (f *Filter) Reload() error {
+	rules, err := f.store.ListRules()
+	if err != nil {
+		return fmt.Errorf("failed to load word list: %w", err)
+	}
+	f.rules.Store(compile(rules))
+	return nil
+}
+
+// Censor replaces blocked words in content with "***"
+func (f *Filter) Censor(room, content string) (result string) {
+	if content == "" {
+		return content
+	}
+	rs := f.rules.Load()
+
+	// Add safety check to prevent crashes
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("recovered from panic while censoring message", "error", r)
+			// On panic, still try to return censored content if possible
+			result = "***"
+		}
+	}()
+
+	// First pass: Check whole words, so symbols inside a word ("$hit") and
+	// letters spelled out with punctuation ("c.h.u.t") are caught, then the
+	// letter runs inside each word
+	var b strings.Builder
+	chunk := make([]rune, 0, 32)
+
+	flush := func() {
+		if len(chunk) == 0 {
+			return
+		}
+		word := string(chunk)
+		if joinable(word) && rs.blocked(room, word) {
+			b.WriteString("***")
+		} else {
+			b.WriteString(censorTokens(rs, room, word))
+		}
+		chunk = chunk[:0]
+	}
+
+	for _, r := range content {
+		if unicode.IsSpace(r) {
+			flush()
+			b.WriteRune(r) // preserve original whitespace
+		} else {
+			chunk = append(chunk, r)
+		}
+	}
+	flush()
+
+	result = b.String()
+
+	// Second pass: Check for spaced-out words (like "b s d k" -> "bsdk")
+	// Only do this check if the content is reasonable length to avoid issues
+	if len(result) > 0 && len(result) < 500 {
+		result = checkSpacedWords(rs, room, result)
+	}
+
+	return result
+}
+
+// censorTokens checks each run of letters and digits in word, preserving
+// the punctuation between them
+func censorTokens(rs *ruleSet, room, word string) string {
+	var b strings.Builder
+	token := make([]rune, 0, 32)
+
+	flush := func() {
+		if len(token) == 0 {
+			return
+		}
+		if rs.blocked(room, string(token)) {
+			b.WriteString("***")
+		} else {
+			b.WriteString(string(token))
+		}
+		token = token[:0]
+	}
+
+	for _, r := range word {
+		if isTokenRune(r) {
+			token = append(token, r)
+		} else {
+			flush()
+			b.WriteRune(r) // preserve original punctuation
+		}
+	}
+	flush()
+
+	return b.String()
+}
+
+// minJoinedLetters is the fewest letters spelled out with punctuation
+// between them ("c.h.u.t") that are checked as one word. Shorter runs are
+// usually abbreviations ("b/c", "M.C.").
+const minJoinedLetters = 3
+
+// joinable reports whether a whitespace-separated word is checked as a
+// whole. Words split by punctuation are only joined when every part is a
+// single letter and there are at least minJoinedLetters of them; symbols
+// used as letters ("$hit", "b!tch") do not split words.
+func joinable(word string) bool {
+	parts := strings.FieldsFunc(word, func(r rune) bool {
+		_, isLeet := leet[r]
+		return !isTokenRune(r) && !isLeet
+	})
+	if len(parts) <= 1 {
+		return true
+	}
+	if len(parts) < minJoinedLetters {
+		return false
+	}
+	for _, part := range parts {
+		letters := 0
+		for _, r := range part {
+			if !unicode.Is(unicode.Mn, r) && !unicode.Is(unicode.Mc, r) && !unicode.Is(unicode.Cf, r) {
+				letters++
+			}
+		}
+		if letters != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// isTokenRune reports whether r belongs to a token: letters, digits and the
+// marks and format characters that may appear inside them
+func isTokenRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Mc, r) || unicode.Is(unicode.Cf, r)
+}
+
+// checkSpacedWords detects words that are spaced out to bypass filtering
+func checkSpacedWords(rs *ruleSet, room, content string) string {
+	// Add safety check to prevent crashes
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("recovered from panic while checking spaced words", "error", r)
+		}
+	}()
+
+	words := strings.Fields(content)
+	if len(words) < 3 { // Require at least 3 words to avoid false positives
+		return content
+	}
+
+	result := make([]string, len(words))
+	copy(result, words)
+
+	// Check for patterns like "b s d k" (single characters with spaces)
+	for i := 0; i < len(words)-2; i++ { // Need at least 3 characters
+		// Look for sequences of single characters
+		var sequence []string
+		var indices []int
+
+		j := i
+		for j < len(words) && len(strings.TrimSpace(words[j])) == 1 && unicode.IsLetter(rune(words[j][0])) {
+			sequence = append(sequence, strings.ToLower(strings.TrimSpace(words[j])))
+			indices = append(indices, j)
+			j++
+		}
+
+		// Only check if we have at least 4 single characters to reduce false positives
+		if len(sequence) >= 4 {
+			combined := strings.Join(sequence, "")
+			if len(combined) >= 4 && rs.blocked(room, combined) {
+				// Replace all the spaced characters with ***
+				for _, idx := range indices {
+					result[idx] = ""
+				}
+				if len(indices) > 0 {
+					result[indices[0]] = "***"
+				}
+			}
+		}
+
+		// Skip ahead to avoid overlapping checks
+		if j > i+1 {
+			i = j - 2
+		}
+	}
+
+	// Filter out empty strings and join
+	var filtered []string
+	for _, word := range result {
+		if word != "" {
+			filtered = append(filtered, word)
+		}
+	}
+
+	return strings.Join(filtered, " ")
+}
@@ -0,0 +1,146 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+type staticStore []models.ProfanityRule
+
+func (s staticStore) ListRules() ([]models.ProfanityRule, error) {
+	return s, nil
+}
+
+func newTestFilter(t *testing.T) *Filter {
+	t.Helper()
+
+	var rules staticStore
+	for _, word := range []string{
+		"aand", "b.c.", "bc", "bhosdike", "bsdk", "chut", "chutiya",
+		"gaand", "gand", "goo", "m.c.", "mc",
+	} {
+		rules = append(rules, models.ProfanityRule{Word: word, Mode: models.ExactMatch})
+	}
+	rules = append(rules,
+		models.ProfanityRule{Word: "madarchod", Mode: models.SubstringMatch},
+		models.ProfanityRule{Word: "gandu", Mode: models.ExactMatch, Room: "roast"},
+	)
+
+	f := New(rules)
+	if err := f.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	return f
+}
+
+type censorCase struct {
+	name    string
+	room    string
+	content string
+	want    string
+}
+
+func runCensorCases(t *testing.T, cases []censorCase) {
+	t.Helper()
+
+	f := newTestFilter(t)
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := f.Censor(tc.room, tc.content); got != tc.want {
+				t.Errorf("Censor(%q, %q) = %q, want %q", tc.room, tc.content, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCensorPlain(t *testing.T) {
+	runCensorCases(t, []censorCase{
+		{name: "exact", content: "you chutiya", want: "you ***"},
+		{name: "upper case", content: "CHUTIYA", want: "***"},
+		{name: "trailing punctuation", content: "chutiya!!", want: "***!!"},
+		{name: "substring", content: "bigmadarchodguy", want: "***"},
+		{name: "room rule in its room", room: "roast", content: "gandu", want: "***"},
+		{name: "room rule elsewhere", room: "general", content: "gandu", want: "gandu"},
+	})
+}
+
+func TestCensorLeet(t *testing.T) {
+	runCensorCases(t, []censorCase{
+		{name: "digits", content: "bh0sd1ke", want: "***"},
+		{name: "digit inside word", content: "chut1ya", want: "***"},
+		{name: "symbol as letter", content: "g@nd", want: "***"},
+	})
+}
+
+func TestCensorSeparators(t *testing.T) {
+	runCensorCases(t, []censorCase{
+		{name: "dots", content: "c.h.u.t", want: "***"},
+		{name: "dashes", content: "b-s-d-k", want: "***"},
+		{name: "mixed", content: "c_h.u-t", want: "***"},
+		{name: "spaced", content: "oh b s d k", want: "oh ***"},
+	})
+}
+
+func TestCensorRepeats(t *testing.T) {
+	runCensorCases(t, []censorCase{
+		{name: "repeated vowels", content: "chuuutiyaaa", want: "***"},
+		{name: "repeated consonants", content: "ggaannd", want: "***"},
+		{name: "doubled word as typed", content: "gaand", want: "***"},
+	})
+}
+
+func TestCensorZeroWidth(t *testing.T) {
+	runCensorCases(t, []censorCase{
+		{name: "zero width space", content: "chu\u200btiya", want: "***"},
+		{name: "zero width joiner", content: "bh\u200dosdike", want: "***"},
+		{name: "soft hyphen", content: "ch\u00adut", want: "***"},
+	})
+}
+
+func TestCensorDevanagari(t *testing.T) {
+	runCensorCases(t, []censorCase{
+		{name: "chutiya", content: "तू चूतिया है", want: "तू *** है"},
+		{name: "bhosdike", content: "भोसडीके", want: "***"},
+	})
+}
+
+func TestCensorHomoglyphs(t *testing.T) {
+	runCensorCases(t, []censorCase{
+		{name: "cyrillic", content: "сhutiyа", want: "***"},
+		{name: "greek", content: "gαnd", want: "***"},
+		{name: "accents", content: "chütíyá", want: "***"},
+		{name: "full width", content: "ｃｈｕｔ", want: "***"},
+	})
+}
+
+func TestCensorFalsePositives(t *testing.T) {
+	runCensorCases(t, []censorCase{
+		{name: "number", content: "900 votes", want: "900 votes"},
+		{name: "number with punctuation", content: "we got 900!", want: "we got 900!"},
+		{name: "repeated letters of a word", content: "aaand", want: "aaand"},
+		{name: "abbreviation with slash", content: "b/c I said", want: "b/c I said"},
+		{name: "initials", content: "M.C. Escher", want: "M.C. Escher"},
+		{name: "stretched word", content: "goood game", want: "goood game"},
+		{name: "word containing a rule", content: "chutney", want: "chutney"},
+	})
+}
+
+func TestAllowRule(t *testing.T) {
+	f := New(staticStore{
+		{Word: "chut", Mode: models.PrefixMatch},
+		{Word: "chutney", Allow: true},
+	})
+	if err := f.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	for content, want := range map[string]string{
+		"chutiya": "***",
+		"chutney": "chutney",
+	} {
+		if got := f.Censor("", content); got != want {
+			t.Errorf("Censor(%q) = %q, want %q", content, got, want)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package filter
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// confusables maps non-Latin letters that look like Latin ones to the
+// letter they imitate. Accented Latin letters are handled by stripping
+// combining marks instead.
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'з': '3', 'і': 'i', 'ї': 'i', 'ј': 'j',
+	'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o', 'п': 'n', 'р': 'p', 'с': 'c', 'т': 't',
+	'у': 'y', 'х': 'x', 'ѕ': 's', 'ԁ': 'd', 'ɡ': 'g', 'һ': 'h', 'ь': 'b',
+	// Greek
+	'α': 'a', 'β': 'b', 'γ': 'y', 'δ': 'd', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k',
+	'ν': 'v', 'ο': 'o', 'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x', 'ω': 'w',
+	// Latin lookalikes that have no decomposition
+	'ı': 'i', 'ł': 'l', 'ø': 'o', 'đ': 'd', 'ħ': 'h', 'ß': 's',
+}
+
+// leet maps digits and symbols commonly used in place of letters
+var leet = map[rune]rune{
+	'0': 'o', '1': 'i', '2': 'z', '3': 'e', '4': 'a', '5': 's', '6': 'g', '7': 't',
+	'8': 'b', '9': 'g', '@': 'a', '$': 's', '!': 'i', '|': 'l', '+': 't', '€': 'e',
+}
+
+// normalize folds a token to the form used for word list lookups:
+//
+//  1. NFKC folding (full-width and stylised letters become plain ones)
+//  2. lower-casing and removal of zero-width and other format characters
+//  3. transliteration of Devanagari to Latin
+//  4. stripping of accents and mapping of look-alike letters
+//  5. digit and symbol to letter substitution ("bh0sd1ke" -> "bhosdike"),
+//     except in numbers ("900" stays a number rather than "goo")
+//  6. removal of everything that is not a letter ("c.h.u.t" -> "chut")
+//
+// Repeated letters are collapsed separately by collapseRepeats.
+func normalize(token string) string {
+	s := strings.ToLower(norm.NFKC.String(token))
+
+	s = strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+		return r
+	}, s)
+
+	if strings.IndexFunc(s, isDevanagari) >= 0 {
+		s = transliterateDevanagari(s)
+	}
+	number := strings.IndexFunc(s, unicode.IsLetter) < 0 && strings.IndexFunc(s, unicode.IsDigit) >= 0
+
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if c, ok := confusables[r]; ok {
+			r = c
+		}
+		if l, ok := leet[r]; ok && !number {
+			r = l
+		}
+		if unicode.IsLetter(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// collapseRepeats squeezes runs of the same letter into one ("chuuutiya" ->
+// "chutiya"). Word list entries are collapsed the same way before they are
+// compared with collapsed tokens.
+func collapseRepeats(s string) string {
+	var b strings.Builder
+	var prev rune = -1
+	for _, r := range s {
+		if r != prev {
+			b.WriteRune(r)
+		}
+		prev = r
+	}
+	return b.String()
+}
+
+func isDevanagari(r rune) bool {
+	return r >= 0x0900 && r <= 0x097F
+}
+
+var devanagariConsonants = map[rune]string{
+	'क': "k", 'ख': "kh", 'ग': "g", 'घ': "gh", 'ङ': "n",
+	'च': "ch", 'छ': "chh", 'ज': "j", 'झ': "jh", 'ञ': "n",
+	'ट': "t", 'ठ': "th", 'ड': "d", 'ढ': "dh", 'ण': "n",
+	'त': "t", 'थ': "th", 'द': "d", 'ध': "dh", 'न': "n",
+	'प': "p", 'फ': "ph", 'ब': "b", 'भ': "bh", 'म': "m",
+	'य': "y", 'र': "r", 'ल': "l", 'व': "v", 'श': "sh",
+	'ष': "sh", 'स': "s", 'ह': "h",
+}
+
+var devanagariVowels = map[rune]string{
+	'अ': "a", 'आ': "aa", 'इ': "i", 'ई': "ii", 'उ': "u", 'ऊ': "uu",
+	'ऋ': "ri", 'ए': "e", 'ऐ': "ai", 'ओ': "o", 'औ': "au",
+}
+
+var devanagariMatras = map[rune]string{
+	'ा': "aa", 'ि': "i", 'ी': "ii", 'ु': "u", 'ू': "uu", 'ृ': "ri",
+	'े': "e", 'ै': "ai", 'ो': "o", 'ौ': "au", 'ॅ': "e", 'ॉ': "o",
+}
+
+const (
+	devanagariVirama      = '्'
+	devanagariNukta       = '़'
+	devanagariAnusvara    = 'ं'
+	devanagariCandrabindu = 'ँ'
+	devanagariVisarga     = 'ः'
+)
+
+// syllable is a consonant or independent vowel with its vowel sign
+type syllable struct {
+	consonant string
+	vowel     string
+	schwa     bool // vowel is the inherent "a" of the consonant
+	coda      string
+	other     string // text that is not Devanagari
+}
+
+// transliterateDevanagari converts Devanagari text to the Latin spelling
+// used in the word list ("चूतिया" -> "chuutiyaa"). Inherent vowels are
+// dropped at the end of a word and between a vowel-consonant and a
+// consonant-vowel pair, which approximates Hindi pronunciation
+// ("भोसडीके" -> "bhosdiike").
+func transliterateDevanagari(s string) string {
+	var syllables []syllable
+	for _, r := range s {
+		last := len(syllables) - 1
+		switch {
+		case devanagariConsonants[r] != "":
+			syllables = append(syllables, syllable{consonant: devanagariConsonants[r], vowel: "a", schwa: true})
+		case devanagariVowels[r] != "":
+			syllables = append(syllables, syllable{vowel: devanagariVowels[r]})
+		case devanagariMatras[r] != "" && last >= 0 && syllables[last].schwa:
+			syllables[last].vowel = devanagariMatras[r]
+			syllables[last].schwa = false
+		case r == devanagariVirama && last >= 0 && syllables[last].schwa:
+			syllables[last].vowel = ""
+			syllables[last].schwa = false
+		case (r == devanagariAnusvara || r == devanagariCandrabindu) && last >= 0:
+			syllables[last].coda = "n"
+			syllables[last].schwa = false
+		case r == devanagariVisarga && last >= 0:
+			syllables[last].coda = "h"
+		case r == devanagariNukta:
+			// Nukta only changes the sound slightly; ignore it
+		case isDevanagari(r):
+			// Digits, danda and other signs carry no letters
+		default:
+			syllables = append(syllables, syllable{other: string(r)})
+		}
+	}
+
+	hasVowel := func(i int) bool {
+		return i >= 0 && i < len(syllables) && syllables[i].other == "" && syllables[i].vowel != ""
+	}
+	isWordEnd := func(i int) bool {
+		return i+1 >= len(syllables) || syllables[i+1].other != ""
+	}
+
+	// Delete inherent vowels right to left
+	for i := len(syllables) - 1; i >= 0; i-- {
+		if !syllables[i].schwa {
+			continue
+		}
+		if isWordEnd(i) || (hasVowel(i-1) && syllables[i+1].consonant != "" && hasVowel(i+1)) {
+			syllables[i].vowel = ""
+			syllables[i].schwa = false
+		}
+	}
+
+	var b strings.Builder
+	for _, syl := range syllables {
+		b.WriteString(syl.other)
+		b.WriteString(syl.consonant)
+		b.WriteString(syl.vowel)
+		b.WriteString(syl.coda)
+	}
+	return b.String()
+}
@@ -0,0 +1,76 @@
+package filter
+
+import "strings"
+
+type TrieNode struct {
+	children map[rune]*TrieNode
+	isEnd    bool
+}
+
+// Trie structure
+type Trie struct {
+	root *TrieNode
+}
+
+// Create new Trie
+func NewTrie() *Trie {
+	return &Trie{root: &TrieNode{children: make(map[rune]*TrieNode)}}
+}
+
+// Insert a word into the Trie
+func (t *Trie) Insert(word string) {
+	w := strings.ToLower(strings.TrimSpace(word))
+	if w == "" {
+		return
+	}
+	node := t.root
+	for _, ch := range w {
+		if node.children[ch] == nil {
+			node.children[ch] = &TrieNode{children: make(map[rune]*TrieNode)}
+		}
+		node = node.children[ch]
+	}
+	node.isEnd = true
+}
+
+// Search checks if the word exists in the Trie
+func (t *Trie) Search(word string) bool {
+	node := t.root
+	for _, ch := range word {
+		if node.children[ch] == nil {
+			return false
+		}
+		node = node.children[ch]
+	}
+	return node.isEnd
+}
+
+// HasPrefixOf checks if any word in the Trie is a prefix of word
+func (t *Trie) HasPrefixOf(word string) bool {
+	return t.prefixOf([]rune(word))
+}
+
+// ContainedIn checks if any word in the Trie appears anywhere inside word
+func (t *Trie) ContainedIn(word string) bool {
+	runes := []rune(word)
+	for i := range runes {
+		if t.prefixOf(runes[i:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Trie) prefixOf(runes []rune) bool {
+	node := t.root
+	for _, ch := range runes {
+		if node.children[ch] == nil {
+			return false
+		}
+		node = node.children[ch]
+		if node.isEnd {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pollz/websocket-server/internal/auth"
+	"github.com/pollz/websocket-server/internal/logging"
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+// AdminHub is the view of the hub used by the admin API
+type AdminHub interface {
+	ListProfanityRules() ([]models.ProfanityRule, error)
+	AddProfanityRule(rule models.ProfanityRule) (models.ProfanityRule, error)
+	DeleteProfanityRule(id int64) error
+	ReloadProfanity() error
+	SetSlowMode(room string, interval time.Duration) error
+	GetSlowModes() map[string]int
+
+	ListClients() []models.ClientInfo
+	DisconnectClient(id, actor string) error
+	ListBans() ([]models.Ban, error)
+	ListMutes() ([]models.Mute, error)
+	Moderate(moderatorID string, role models.Role, room string, cmd models.ModCommand) (string, error)
+
+	Announce(room, text, actor string) (models.Message, error)
+	ClearRoom(room, actor string) (int64, error)
+	PinMessage(room, id, actor string) (models.Message, error)
+	UnpinMessage(room, actor string) error
+
+	GetSuperChatTotals(group, room string, limit int) ([]models.SuperChatTotal, error)
+
+	ListStickerPacks() []models.StickerPack
+	SaveStickerPack(pack models.StickerPack) (models.StickerPack, error)
+	SetStickerPackEnabled(id string, enabled bool) error
+	DeleteStickerPack(id string) error
+}
+
+// apiKeyActor is recorded in the audit log for requests made with the
+// admin API key
+const apiKeyActor = "api-key"
+
+type actorKey struct{}
+
+type AdminHandler struct {
+	hub      AdminHub
+	apiKey   string
+	verifier *auth.Verifier
+}
+
+func NewAdminHandler(hub AdminHub, apiKey string, verifier *auth.Verifier) *AdminHandler {
+	return &AdminHandler{
+		hub:      hub,
+		apiKey:   apiKey,
+		verifier: verifier,
+	}
+}
+
+// Authorize only lets requests through that carry the admin API key in the
+// X-Admin-Key header or a bearer access token with the admin role. The
+// caller is recorded in the request context for the audit log.
+func (h *AdminHandler) Authorize(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if key := r.Header.Get("X-Admin-Key"); key != "" {
+			if h.apiKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(h.apiKey)) != 1 {
+				sendError(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next(w, r.WithContext(context.WithValue(r.Context(), actorKey{}, apiKeyActor)))
+			return
+		}
+
+		header := r.Header.Get("Authorization")
+		if !strings.HasPrefix(header, "Bearer ") {
+			sendError(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		claims, err := h.verifier.Verify(strings.TrimPrefix(header, "Bearer "))
+		if err != nil {
+			sendError(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if models.ParseRole(claims.Role) != models.RoleAdmin {
+			sendError(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), actorKey{}, claims.UserID)))
+	}
+}
+
+// actor returns who made an authorized admin request
+func actor(r *http.Request) string {
+	if id, ok := r.Context().Value(actorKey{}).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// ProfanityRules handles GET, POST and DELETE /api/admin/profanity
+//
+//	GET                       lists all rules
+//	POST {"word": "...", "mode": "exact|prefix|substring", "room": "...", "allow": false}
+//	DELETE ?id=42             removes a rule
+func (h *AdminHandler) ProfanityRules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		rules, err := h.hub.ListProfanityRules()
+		if err != nil {
+			logging.FromContext(r.Context()).Error("failed to list profanity rules", "error", err)
+			sendError(w, "Failed to list rules", http.StatusInternalServerError)
+			return
+		}
+		if rules == nil {
+			rules = []models.ProfanityRule{}
+		}
+		sendJSON(w, rules)
+
+	case http.MethodPost:
+		var rule models.ProfanityRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			sendError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		rule, err := h.hub.AddProfanityRule(rule)
+		if err != nil {
+			h.sendHubError(w, r, "Failed to add rule", err)
+			return
+		}
+		sendJSONStatus(w, http.StatusCreated, rule)
+
+	case http.MethodDelete:
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			sendError(w, "Invalid rule id", http.StatusBadRequest)
+			return
+		}
+		if err := h.hub.DeleteProfanityRule(id); err != nil {
+			h.sendHubError(w, r, "Failed to delete rule", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ReloadProfanity handles POST /api/admin/profanity/reload
+func (h *AdminHandler) ReloadProfanity(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := h.hub.ReloadProfanity(); err != nil {
+		logging.FromContext(r.Context()).Error("failed to reload profanity rules", "error", err)
+		sendError(w, "Failed to reload rules", http.StatusInternalServerError)
+		return
+	}
+	sendJSON(w, map[string]string{"status": "reloaded"})
+}
+
+// SlowMode handles GET and POST /api/admin/rooms/slowmode
+//
+//	GET                                  lists rooms in slow mode
+//	POST {"room": "live", "seconds": 10}  sets the interval, 0 turns it off
+func (h *AdminHandler) SlowMode(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		sendJSON(w, h.hub.GetSlowModes())
+
+	case http.MethodPost:
+		var req struct {
+			Room    string `json:"room"`
+			Seconds int    `json:"seconds"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := h.hub.SetSlowMode(req.Room, time.Duration(req.Seconds)*time.Second); err != nil {
+			h.sendHubError(w, r, "Failed to set slow mode", err)
+			return
+		}
+		sendJSON(w, req)
+
+	default:
+		sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// sendHubError maps hub errors to HTTP status codes
+func (h *AdminHandler) sendHubError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	switch {
+	case errors.Is(err, models.ErrInvalidInput):
+		sendError(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, models.ErrForbidden):
+		sendError(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, models.ErrNotFound):
+		sendError(w, "Not found", http.StatusNotFound)
+	default:
+		logging.FromContext(r.Context()).Error(message, "error", err)
+		sendError(w, message, http.StatusInternalServerError)
+	}
+}
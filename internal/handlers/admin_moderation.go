@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+// Clients handles GET and DELETE /api/admin/clients. Only the clients of
+// the instance serving the request are listed.
+//
+//	GET                 lists connected clients
+//	DELETE ?id=<id>     disconnects a client
+func (h *AdminHandler) Clients(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		sendJSON(w, h.hub.ListClients())
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			sendError(w, "Missing client id", http.StatusBadRequest)
+			return
+		}
+		if err := h.hub.DisconnectClient(id, actor(r)); err != nil {
+			h.sendHubError(w, r, "Failed to disconnect client", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Bans handles GET, POST and DELETE /api/admin/bans
+//
+//	GET                                   lists active bans
+//	POST {"user_id": "42", "ip": "", "duration": 3600, "reason": "..."}
+//	DELETE ?user_id=42 or ?ip=1.2.3.4     lifts bans
+func (h *AdminHandler) Bans(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		bans, err := h.hub.ListBans()
+		if err != nil {
+			h.sendHubError(w, r, "Failed to list bans", err)
+			return
+		}
+		if bans == nil {
+			bans = []models.Ban{}
+		}
+		sendJSON(w, bans)
+
+	case http.MethodPost:
+		var cmd models.ModCommand
+		if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+			sendError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		cmd.Action = models.ModBan
+		h.moderate(w, r, cmd, http.StatusCreated)
+
+	case http.MethodDelete:
+		h.moderate(w, r, models.ModCommand{
+			Action: models.ModUnban,
+			UserID: r.URL.Query().Get("user_id"),
+			IP:     r.URL.Query().Get("ip"),
+		}, http.StatusOK)
+
+	default:
+		sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Mutes handles GET, POST and DELETE /api/admin/mutes
+//
+//	GET                                              lists muted users
+//	POST {"user_id": "42", "duration": 600, "reason": "..."}
+//	DELETE ?user_id=42                               unmutes a user
+func (h *AdminHandler) Mutes(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		mutes, err := h.hub.ListMutes()
+		if err != nil {
+			h.sendHubError(w, r, "Failed to list mutes", err)
+			return
+		}
+		sendJSON(w, mutes)
+
+	case http.MethodPost:
+		var cmd models.ModCommand
+		if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+			sendError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		cmd.Action = models.ModMute
+		h.moderate(w, r, cmd, http.StatusCreated)
+
+	case http.MethodDelete:
+		h.moderate(w, r, models.ModCommand{
+			Action: models.ModUnmute,
+			UserID: r.URL.Query().Get("user_id"),
+		}, http.StatusOK)
+
+	default:
+		sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// moderate runs a moderation command and reports its result
+func (h *AdminHandler) moderate(w http.ResponseWriter, r *http.Request, cmd models.ModCommand, status int) {
+	result, err := h.hub.Moderate(actor(r), models.RoleAdmin, "", cmd)
+	if err != nil {
+		h.sendHubError(w, r, "Failed to "+cmd.Action, err)
+		return
+	}
+	sendJSONStatus(w, status, map[string]string{"result": result})
+}
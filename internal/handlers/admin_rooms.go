@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Announce handles POST /api/admin/rooms/announce
+//
+//	POST {"room": "live", "message": "..."}   posts a system message
+func (h *AdminHandler) Announce(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Room    string `json:"room"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	message, err := h.hub.Announce(req.Room, req.Message, actor(r))
+	if err != nil {
+		h.sendHubError(w, r, "Failed to post announcement", err)
+		return
+	}
+	sendJSONStatus(w, http.StatusCreated, message)
+}
+
+// ClearRoom handles POST /api/admin/rooms/clear
+//
+//	POST {"room": "live"}   deletes every message in the room
+func (h *AdminHandler) ClearRoom(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Room string `json:"room"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := h.hub.ClearRoom(req.Room, actor(r))
+	if err != nil {
+		h.sendHubError(w, r, "Failed to clear room", err)
+		return
+	}
+	sendJSON(w, map[string]interface{}{"room": req.Room, "deleted": deleted})
+}
+
+// PinMessage handles POST and DELETE /api/admin/rooms/pin
+//
+//	POST {"room": "live", "message_id": "..."}   pins a message
+//	DELETE ?room=live                            unpins the room's message
+func (h *AdminHandler) PinMessage(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var req struct {
+			Room      string `json:"room"`
+			MessageID string `json:"message_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		message, err := h.hub.PinMessage(req.Room, req.MessageID, actor(r))
+		if err != nil {
+			h.sendHubError(w, r, "Failed to pin message", err)
+			return
+		}
+		sendJSON(w, message)
+
+	case http.MethodDelete:
+		if err := h.hub.UnpinMessage(r.URL.Query().Get("room"), actor(r)); err != nil {
+			h.sendHubError(w, r, "Failed to unpin message", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
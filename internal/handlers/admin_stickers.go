@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+// StickerPacks handles GET, POST and DELETE /api/admin/stickers
+//
+//	GET                   lists all packs, including disabled ones
+//	POST {"id": "debate", "name": "Debate night", "room": "debate", "enabled": true,
+//	      "stickers": [{"id": "debate_mic", "name": "Mic", "image_url": "..."}]}
+//	                      creates or replaces a pack
+//	DELETE ?id=debate     removes a pack
+func (h *AdminHandler) StickerPacks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		sendJSON(w, h.hub.ListStickerPacks())
+
+	case http.MethodPost:
+		var pack models.StickerPack
+		if err := json.NewDecoder(r.Body).Decode(&pack); err != nil {
+			sendError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		pack, err := h.hub.SaveStickerPack(pack)
+		if err != nil {
+			h.sendHubError(w, r, "Failed to save sticker pack", err)
+			return
+		}
+		sendJSONStatus(w, http.StatusCreated, pack)
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			sendError(w, "Missing pack id", http.StatusBadRequest)
+			return
+		}
+		if err := h.hub.DeleteStickerPack(id); err != nil {
+			h.sendHubError(w, r, "Failed to delete sticker pack", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// EnableStickerPack handles POST /api/admin/stickers/enable
+//
+//	POST {"id": "debate", "enabled": false}   enables or disables a pack
+func (h *AdminHandler) EnableStickerPack(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ID      string `json:"id"`
+		Enabled bool   `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := h.hub.SetStickerPackEnabled(req.ID, req.Enabled); err != nil {
+		h.sendHubError(w, r, "Failed to update sticker pack", err)
+		return
+	}
+	sendJSON(w, req)
+}
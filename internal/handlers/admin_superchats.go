@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// SuperChatTotals handles GET /api/admin/superchats/totals
+//
+//	GET ?group=user&room=live&limit=50   totals per user, optionally in one room
+//	GET ?group=room&limit=50             totals per room
+func (h *AdminHandler) SuperChatTotals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	group := r.URL.Query().Get("group")
+	if group == "" {
+		group = "user"
+	}
+	limit := 50
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+
+	totals, err := h.hub.GetSuperChatTotals(group, r.URL.Query().Get("room"), limit)
+	if err != nil {
+		h.sendHubError(w, r, "Failed to get superchat totals", err)
+		return
+	}
+	sendJSON(w, totals)
+}
@@ -1,108 +1,219 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/pollz/websocket-server/internal/models"
+	"github.com/pollz/websocket-server/internal/persistence"
 )
 
+// MessageHub is the read-only view of the hub used by the REST API
+type MessageHub interface {
+	SearchMessages(q models.SearchQuery) (models.SearchPage, error)
+	GetMessagesByDateRange(room string, start, end time.Time) ([]models.Message, error)
+	GetThread(id string, limit, offset int) (models.Thread, error)
+	GetHistory(room, before, after string, limit int) (models.HistoryPage, error)
+	GetConnectedClients() int
+	GetRoomCounts() map[string]int
+	GetPersistenceStats() persistence.Stats
+	GetStickerPacks(room string) []models.StickerPack
+}
+
 type APIHandler struct {
-	hub interface {
-		SearchMessages(query string, limit int) ([]models.Message, error)
-		GetMessagesByDateRange(start, end time.Time) ([]models.Message, error)
-		GetConnectedClients() int
-	}
+	hub MessageHub
 }
 
-func NewAPIHandler(hub interface {
-	SearchMessages(query string, limit int) ([]models.Message, error)
-	GetMessagesByDateRange(start, end time.Time) ([]models.Message, error)
-	GetConnectedClients() int
-}) *APIHandler {
+func NewAPIHandler(hub MessageHub) *APIHandler {
 	return &APIHandler{
 		hub: hub,
 	}
 }
 
-// SearchMessages handles GET /api/messages/search?q=query&limit=50
+// GetMessages handles GET /api/messages?room=live&before=<cursor>&limit=50.
+// Pass after instead of before to page forward; without either the newest
+// messages are returned.
+func (h *APIHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	room := query.Get("room")
+	if room == "" {
+		room = models.DefaultRoom
+	}
+	limit, _ := strconv.Atoi(query.Get("limit"))
+
+	page, err := h.hub.GetHistory(room, query.Get("before"), query.Get("after"), limit)
+	if errors.Is(err, models.ErrInvalidInput) {
+		sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		sendError(w, "Failed to get messages", http.StatusInternalServerError)
+		return
+	}
+
+	sendJSON(w, page)
+}
+
+// SearchMessages handles GET /api/messages/search?q="exact phrase" vot*&room=live
+// with optional user_id, type, since and until (dates or RFC 3339 times),
+// limit and offset
 func (h *APIHandler) SearchMessages(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query().Get("q")
-	limitStr := r.URL.Query().Get("limit")
-	
-	limit := 50
-	if limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			limit = l
-		}
+	params := r.URL.Query()
+	q := models.SearchQuery{
+		Text:   params.Get("q"),
+		Room:   params.Get("room"),
+		UserID: params.Get("user_id"),
+		Type:   models.MessageType(params.Get("type")),
+	}
+	q.Limit, _ = strconv.Atoi(params.Get("limit"))
+	q.Offset, _ = strconv.Atoi(params.Get("offset"))
+
+	var err error
+	if q.Since, err = parseSearchTime(params.Get("since"), false); err != nil {
+		sendError(w, "Invalid since", http.StatusBadRequest)
+		return
+	}
+	if q.Until, err = parseSearchTime(params.Get("until"), true); err != nil {
+		sendError(w, "Invalid until", http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.hub.SearchMessages(q)
+	if errors.Is(err, models.ErrInvalidInput) {
+		sendError(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	
-	messages, err := h.hub.SearchMessages(query, limit)
 	if err != nil {
-		h.sendError(w, "Failed to search messages", http.StatusInternalServerError)
+		sendError(w, "Failed to search messages", http.StatusInternalServerError)
 		return
 	}
-	
-	h.sendJSON(w, messages)
+
+	sendJSON(w, page)
+}
+
+// parseSearchTime parses a date or an RFC 3339 time. Dates used as the end
+// of a range include the whole day.
+func parseSearchTime(s string, end bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		if end {
+			t = t.Add(24 * time.Hour)
+		}
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
 }
 
-// GetMessagesByDate handles GET /api/messages/date?start=2024-01-01&end=2024-01-31
+// GetMessagesByDate handles GET /api/messages/date?start=2024-01-01&end=2024-01-31&room=live
 func (h *APIHandler) GetMessagesByDate(w http.ResponseWriter, r *http.Request) {
 	startStr := r.URL.Query().Get("start")
 	endStr := r.URL.Query().Get("end")
-	
+	room := r.URL.Query().Get("room")
+
 	start, err := time.Parse("2006-01-02", startStr)
 	if err != nil {
-		h.sendError(w, "Invalid start date format", http.StatusBadRequest)
+		sendError(w, "Invalid start date format", http.StatusBadRequest)
 		return
 	}
-	
+
 	end, err := time.Parse("2006-01-02", endStr)
 	if err != nil {
-		h.sendError(w, "Invalid end date format", http.StatusBadRequest)
+		sendError(w, "Invalid end date format", http.StatusBadRequest)
 		return
 	}
-	
-	messages, err := h.hub.GetMessagesByDateRange(start, end.Add(24*time.Hour))
+
+	messages, err := h.hub.GetMessagesByDateRange(room, start, end.Add(24*time.Hour))
 	if err != nil {
-		h.sendError(w, "Failed to get messages", http.StatusInternalServerError)
+		sendError(w, "Failed to get messages", http.StatusInternalServerError)
 		return
 	}
-	
-	h.sendJSON(w, messages)
+
+	sendJSON(w, messages)
+}
+
+// GetThread handles GET /api/messages/thread?id=<message id>&limit=50&offset=0
+func (h *APIHandler) GetThread(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		sendError(w, "Missing message id", http.StatusBadRequest)
+		return
+	}
+
+	limit := 50
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+	offset := 0
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o > 0 {
+		offset = o
+	}
+
+	thread, err := h.hub.GetThread(id, limit, offset)
+	if errors.Is(err, sql.ErrNoRows) {
+		sendError(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		sendError(w, "Failed to get thread", http.StatusInternalServerError)
+		return
+	}
+
+	sendJSON(w, thread)
+}
+
+// GetStickers handles GET /api/stickers?room=live. The response carries an
+// ETag so clients can revalidate their copy with If-None-Match.
+func (h *APIHandler) GetStickers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := json.Marshal(h.hub.GetStickerPacks(r.URL.Query().Get("room")))
+	if err != nil {
+		sendError(w, "Failed to encode stickers", http.StatusInternalServerError)
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	// Packs are enabled and disabled during events, so clients revalidate
+	// on every use
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
 }
 
 // GetStats handles GET /api/stats
 func (h *APIHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 	stats := map[string]interface{}{
 		"connected_clients": h.hub.GetConnectedClients(),
-		"server_time":      time.Now(),
+		"rooms":             h.hub.GetRoomCounts(),
+		"persistence":       h.hub.GetPersistenceStats(),
+		"server_time":       time.Now(),
 	}
-	
-	h.sendJSON(w, stats)
+
+	sendJSON(w, stats)
 }
 
 // Health check endpoint
 func (h *APIHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	h.sendJSON(w, map[string]string{
+	sendJSON(w, map[string]string{
 		"status": "healthy",
 		"time":   time.Now().Format(time.RFC3339),
 	})
 }
-
-func (h *APIHandler) sendJSON(w http.ResponseWriter, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(data); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-	}
-}
-
-func (h *APIHandler) sendError(w http.ResponseWriter, message string, status int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(map[string]string{
-		"error": message,
-	})
-}
\ No newline at end of file
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pollz/websocket-server/internal/logging"
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+// InternalHub is the view of the hub used by the backend-facing API
+type InternalHub interface {
+	PublishPollTally(tally models.PollTally) error
+	IngestEvent(event models.Event) (models.EventResult, error)
+}
+
+const (
+	// maxEventSize bounds signed request bodies
+	maxEventSize = 64 * 1024
+
+	// signatureMaxAge is how old a signed request may be, limiting replays
+	signatureMaxAge = 5 * time.Minute
+)
+
+// InternalHandler serves the endpoints the Pollz backend calls to push
+// updates into the real-time layer
+type InternalHandler struct {
+	hub           InternalHub
+	apiKey        string
+	signingSecret []byte
+}
+
+func NewInternalHandler(hub InternalHub, apiKey, signingSecret string) *InternalHandler {
+	return &InternalHandler{
+		hub:           hub,
+		apiKey:        apiKey,
+		signingSecret: []byte(signingSecret),
+	}
+}
+
+// Authorize only lets requests carrying the internal API key in the
+// X-Internal-Key header through. The internal API is disabled when no key
+// is configured.
+func (h *InternalHandler) Authorize(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.apiKey == "" {
+			sendError(w, "Internal API is disabled", http.StatusForbidden)
+			return
+		}
+		key := r.Header.Get("X-Internal-Key")
+		if subtle.ConstantTimeCompare([]byte(key), []byte(h.apiKey)) != 1 {
+			sendError(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// VerifySignature only lets requests through whose body is signed with the
+// shared signing secret. The backend sends the Unix time in
+// X-Pollz-Timestamp and hex(HMAC-SHA256(secret, timestamp + "." + body)) in
+// X-Pollz-Signature, optionally prefixed with "sha256=".
+func (h *InternalHandler) VerifySignature(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(h.signingSecret) == 0 {
+			sendError(w, "Internal API is disabled", http.StatusForbidden)
+			return
+		}
+
+		timestamp := r.Header.Get("X-Pollz-Timestamp")
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			sendError(w, "Missing or invalid timestamp", http.StatusUnauthorized)
+			return
+		}
+		if age := time.Since(time.Unix(unix, 0)); age > signatureMaxAge || age < -signatureMaxAge {
+			sendError(w, "Request expired", http.StatusUnauthorized)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxEventSize))
+		if err != nil {
+			sendError(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		signature, err := hex.DecodeString(strings.TrimPrefix(r.Header.Get("X-Pollz-Signature"), "sha256="))
+		if err != nil {
+			sendError(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
+		mac := hmac.New(sha256.New, h.signingSecret)
+		mac.Write([]byte(timestamp + "."))
+		mac.Write(body)
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			sendError(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next(w, r)
+	}
+}
+
+// Events handles POST /api/internal/events
+//
+//	POST {"idempotency_key": "...", "type": "system|announcement|superchat|poll_results", "room": "live", ...}
+func (h *InternalHandler) Events(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var event models.Event
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.hub.IngestEvent(event)
+	switch {
+	case errors.Is(err, models.ErrInvalidInput):
+		sendError(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, models.ErrEventInProgress):
+		sendError(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		logging.FromContext(r.Context()).Error("failed to ingest event", "type", event.Type, "error", err)
+		sendError(w, "Failed to publish event", http.StatusInternalServerError)
+	case result.Duplicate:
+		sendJSON(w, result)
+	default:
+		sendJSONStatus(w, http.StatusCreated, result)
+	}
+}
+
+// PollTally handles POST /api/internal/polls
+//
+//	POST {"poll_id": "42", "counts": {"1": 10, "2": 7}, "total_votes": 17, "updated_at": "..."}
+func (h *InternalHandler) PollTally(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var tally models.PollTally
+	if err := json.NewDecoder(r.Body).Decode(&tally); err != nil {
+		sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.hub.PublishPollTally(tally); err != nil {
+		if errors.Is(err, models.ErrInvalidInput) {
+			sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logging.FromContext(r.Context()).Error("failed to publish poll tally", "poll_id", tally.PollID, "error", err)
+		sendError(w, "Failed to publish poll tally", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -1,23 +1,25 @@
 package handlers
 
 import (
-	"log"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/pollz/websocket-server/internal/auth"
+	"github.com/pollz/websocket-server/internal/logging"
 	"github.com/pollz/websocket-server/internal/models"
 	ws "github.com/pollz/websocket-server/internal/websocket"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-}
+// Close codes sent when the handshake token is rejected
+const (
+	CloseInvalidToken = 4001
+	CloseTokenExpired = 4002
+)
 
 type connectionInfo struct {
 	count     int
@@ -25,27 +27,72 @@ type connectionInfo struct {
 }
 
 type WebSocketHandler struct {
-	hub         models.Hub
-	connections map[string]*connectionInfo
-	mutex       sync.RWMutex
+	hub            models.Hub
+	verifier       *auth.Verifier
+	allowAnonymous bool
+	proxies        TrustedProxies
+	upgrader       websocket.Upgrader
+	connections    map[string]*connectionInfo
+	mutex          sync.RWMutex
 }
 
-func NewWebSocketHandler(hub models.Hub) *WebSocketHandler {
+// NewWebSocketHandler returns the chat endpoint handler. compression
+// enables permessage-deflate for clients that offer it; proxies are the
+// reverse proxies trusted to report client addresses.
+func NewWebSocketHandler(hub models.Hub, verifier *auth.Verifier, allowAnonymous, compression bool, proxies TrustedProxies) *WebSocketHandler {
 	return &WebSocketHandler{
-		hub:         hub,
+		hub:            hub,
+		verifier:       verifier,
+		allowAnonymous: allowAnonymous,
+		proxies:        proxies,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool {
+				return true
+			},
+			ReadBufferSize:    1024,
+			WriteBufferSize:   1024,
+			EnableCompression: compression,
+			Subprotocols:      ws.Subprotocols,
+		},
 		connections: make(map[string]*connectionInfo),
 	}
 }
 
 func (h *WebSocketHandler) getClientIP(r *http.Request) string {
-	// Get real IP from headers (for reverse proxy setups)
-	if ip := r.Header.Get("X-Forwarded-For"); ip != "" {
-		return ip
+	return h.proxies.clientIP(r)
+}
+
+// getRoom returns the room requested by the URL path (/ws/chat/{room}) or the
+// room query parameter, falling back to the default room.
+func (h *WebSocketHandler) getRoom(r *http.Request) string {
+	room := strings.Trim(strings.TrimPrefix(r.URL.Path, "/ws/chat/"), "/")
+	if room == "" {
+		room = r.URL.Query().Get("room")
+	}
+	if room == "" {
+		return models.DefaultRoom
 	}
-	if ip := r.Header.Get("X-Real-IP"); ip != "" {
-		return ip
+	return room
+}
+
+// getToken returns the access token from the token query parameter or a
+// bearer Authorization header. Browsers cannot set headers on WebSocket
+// requests, so the query parameter is the usual source.
+func (h *WebSocketHandler) getToken(r *http.Request) string {
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token
+	}
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer ")
 	}
-	return r.RemoteAddr
+	return ""
+}
+
+// reject closes a freshly upgraded connection with the given close code
+func (h *WebSocketHandler) reject(conn *websocket.Conn, code int, reason string) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	conn.Close()
 }
 
 func (h *WebSocketHandler) checkRateLimit(ip string) bool {
@@ -85,33 +132,70 @@ func (h *WebSocketHandler) checkRateLimit(ip string) bool {
 func (h *WebSocketHandler) HandleConnection(w http.ResponseWriter, r *http.Request) {
 	// Get client IP for rate limiting
 	clientIP := h.getClientIP(r)
-	
+	logger := logging.FromContext(r.Context())
+
 	// Check rate limit
 	if !h.checkRateLimit(clientIP) {
-		log.Printf("Rate limit exceeded for IP: %s", clientIP)
+		logger.Warn("connection rate limit exceeded", logging.IPKey, clientIP)
 		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
 		return
 	}
 
+	room := h.getRoom(r)
+	if !models.ValidRoom(room) {
+		http.Error(w, "Invalid room", http.StatusBadRequest)
+		return
+	}
+
 	// Upgrade HTTP connection to WebSocket
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("Failed to upgrade connection: %v", err)
+		logger.Warn("failed to upgrade connection", "error", err)
 		return
 	}
 
-	// Extract user info from query params or headers (implement your auth logic here)
-	userID := r.URL.Query().Get("user_id")
-	username := r.URL.Query().Get("username")
-	
-	// If no username provided, use anonymous
-	if username == "" {
-		username = "Anonymous"
+	opts := ws.Options{
+		Username: "Anonymous",
+		Room:     room,
+		Role:     models.RoleUser,
+		IP:       clientIP,
+		Logger:   logger,
+	}
+	if polls := r.URL.Query().Get("polls"); polls != "" {
+		opts.Polls = strings.Split(polls, ",")
+	}
+	if lastSeq, err := strconv.ParseInt(r.URL.Query().Get("last_seq"), 10, 64); err == nil && lastSeq > 0 {
+		opts.LastSeq = lastSeq
+	}
+	opts.Backpressure = models.ParseBackpressurePolicy(r.URL.Query().Get("backpressure"))
+
+	// Identify the user from a token signed by the Pollz backend
+	if token := h.getToken(r); token != "" {
+		claims, err := h.verifier.Verify(token)
+		if err != nil {
+			logger.Info("rejected token", logging.IPKey, clientIP, "error", err)
+			if errors.Is(err, auth.ErrTokenExpired) {
+				h.reject(conn, CloseTokenExpired, "token expired")
+			} else {
+				h.reject(conn, CloseInvalidToken, "invalid token")
+			}
+			return
+		}
+		opts.UserID = claims.UserID
+		opts.Role = models.ParseRole(claims.Role)
+		if claims.Username != "" {
+			opts.Username = claims.Username
+		}
+	} else if h.allowAnonymous {
+		opts.ReadOnly = true
+	} else {
+		h.reject(conn, CloseInvalidToken, "authentication required")
+		return
 	}
 
 	// Create new client
-	client := ws.NewClient(h.hub, conn, userID, username)
-	
+	client := ws.NewClient(h.hub, conn, opts)
+
 	// Start client
 	client.Start()
-}
\ No newline at end of file
+}
@@ -0,0 +1,242 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pollz/websocket-server/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// pinnedKey is a Redis hash of room name to the JSON of its pinned message
+const pinnedKey = "pollz:pinned"
+
+// ListClients describes the clients connected to this instance
+func (h *Hub) ListClients() []models.ClientInfo {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	clients := make([]models.ClientInfo, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, models.ClientInfo{
+			ID:       client.ID,
+			UserID:   client.UserID,
+			Username: client.Username,
+			IP:       client.IP,
+			Room:     client.Room,
+			Role:     client.Role,
+			ReadOnly: client.ReadOnly,
+			JoinedAt: client.JoinedAt,
+		})
+	}
+	return clients
+}
+
+// DisconnectClient closes a connection to this instance on behalf of an
+// administrator
+func (h *Hub) DisconnectClient(id, actor string) error {
+	h.mu.Lock()
+	var target *models.Client
+	for client := range h.clients {
+		if client.ID == id {
+			target = client
+			break
+		}
+	}
+	if target == nil {
+		h.mu.Unlock()
+		return fmt.Errorf("client %s: %w", id, models.ErrNotFound)
+	}
+	target.CloseCode = models.CloseKicked
+	target.CloseReason = "disconnected by an administrator"
+	h.removeClient(target, reasonAdmin)
+	h.mu.Unlock()
+
+	h.audit(models.ModerationAction{
+		Action:      models.ModDisconnect,
+		ModeratorID: actor,
+		UserID:      target.UserID,
+		IP:          target.IP,
+		Room:        target.Room,
+	})
+	return nil
+}
+
+// ListBans returns the active bans
+func (h *Hub) ListBans() ([]models.Ban, error) {
+	return h.moderationRepo.ActiveBans()
+}
+
+// ListMutes returns the users currently muted on any instance
+func (h *Hub) ListMutes() ([]models.Mute, error) {
+	ctx := context.Background()
+	now := time.Now()
+
+	mutes := []models.Mute{}
+	iter := h.redis.Scan(ctx, 0, muteKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		ttl, err := h.redis.PTTL(ctx, key).Result()
+		if err != nil || ttl <= 0 {
+			continue
+		}
+		mutes = append(mutes, models.Mute{
+			UserID:    strings.TrimPrefix(key, muteKeyPrefix),
+			ExpiresAt: now.Add(ttl),
+		})
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list mutes: %w", err)
+	}
+	return mutes, nil
+}
+
+// Announce posts a system message to a room
+func (h *Hub) Announce(room, text, actor string) (models.Message, error) {
+	if !models.ValidRoom(room) {
+		return models.Message{}, fmt.Errorf("%w: invalid room %q", models.ErrInvalidInput, room)
+	}
+	if strings.TrimSpace(text) == "" {
+		return models.Message{}, fmt.Errorf("%w: empty message", models.ErrInvalidInput)
+	}
+
+	message := models.Message{
+		ID:        uuid.New().String(),
+		Type:      models.SystemMessage,
+		Room:      room,
+		Content:   text,
+		CreatedAt: time.Now(),
+	}
+	h.Broadcast(message)
+
+	h.audit(models.ModerationAction{
+		Action:      models.ModAnnounce,
+		ModeratorID: actor,
+		MessageID:   message.ID,
+		Room:        room,
+	})
+	return message, nil
+}
+
+// ClearRoom deletes every message in a room and tells its clients to clear
+// their history. It returns the number of stored messages deleted.
+// Messages are cleared up to the room's current sequence number, including
+// those still being cached or waiting to be written.
+func (h *Hub) ClearRoom(room, actor string) (int64, error) {
+	if !models.ValidRoom(room) {
+		return 0, fmt.Errorf("%w: invalid room %q", models.ErrInvalidInput, room)
+	}
+
+	seq, err := h.currentSeq(room)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get sequence number: %w", err)
+	}
+	n, err := h.messageRepo.TombstoneRoom(room, seq, actor)
+	if err != nil {
+		return 0, err
+	}
+	if err := h.messageCache.ClearUpTo(room, seq); err != nil {
+		slog.Error("failed to clear cached messages", "room", room, "error", err)
+	}
+	h.unpin(room)
+	if err := h.redis.Del(context.Background(), superChatKeyPrefix+room).Err(); err != nil {
+		slog.Error("failed to clear pinned superchats", "room", room, "error", err)
+	}
+
+	h.Broadcast(models.Message{
+		Type: models.MessagesCleared,
+		Room: room,
+	})
+
+	h.audit(models.ModerationAction{
+		Action:      models.ModClear,
+		ModeratorID: actor,
+		Room:        room,
+	})
+	return n, nil
+}
+
+// PinMessage pins a message to the top of its room on every instance,
+// replacing the previously pinned one
+func (h *Hub) PinMessage(room, id, actor string) (models.Message, error) {
+	message, found, err := h.findMessage(room, id)
+	if err != nil {
+		return models.Message{}, err
+	}
+	if !found {
+		return models.Message{}, fmt.Errorf("message %s: %w", id, models.ErrNotFound)
+	}
+
+	if err := h.redis.HSet(context.Background(), pinnedKey, room, mustMarshalString(message)).Err(); err != nil {
+		return models.Message{}, fmt.Errorf("failed to pin message: %w", err)
+	}
+
+	h.Broadcast(models.Message{
+		Type:      models.MessagePinned,
+		Room:      room,
+		MessageID: message.ID,
+		Pinned:    &message,
+	})
+
+	h.audit(models.ModerationAction{
+		Action:      models.ModPin,
+		ModeratorID: actor,
+		MessageID:   id,
+		Room:        room,
+	})
+	return message, nil
+}
+
+// UnpinMessage removes a room's pinned message
+func (h *Hub) UnpinMessage(room, actor string) error {
+	if h.pinnedMessage(room) == nil {
+		return fmt.Errorf("no pinned message in %s: %w", room, models.ErrNotFound)
+	}
+	h.unpin(room)
+
+	h.audit(models.ModerationAction{
+		Action:      models.ModUnpin,
+		ModeratorID: actor,
+		Room:        room,
+	})
+	return nil
+}
+
+// unpin removes a room's pinned message and tells its clients
+func (h *Hub) unpin(room string) {
+	removed, err := h.redis.HDel(context.Background(), pinnedKey, room).Result()
+	if err != nil {
+		slog.Error("failed to unpin message", "room", room, "error", err)
+		return
+	}
+	if removed == 0 {
+		return
+	}
+	h.Broadcast(models.Message{
+		Type: models.MessageUnpinned,
+		Room: room,
+	})
+}
+
+// pinnedMessage returns the room's pinned message, or nil if there is none
+func (h *Hub) pinnedMessage(room string) *models.Message {
+	data, err := h.redis.HGet(context.Background(), pinnedKey, room).Result()
+	if err != nil {
+		if err != redis.Nil {
+			slog.Error("failed to get pinned message", "room", room, "error", err)
+		}
+		return nil
+	}
+
+	var message models.Message
+	if err := json.Unmarshal([]byte(data), &message); err != nil {
+		slog.Error("failed to decode pinned message", "room", room, "error", err)
+		return nil
+	}
+	return &message
+}
@@ -0,0 +1,173 @@
+package hub
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pollz/websocket-server/internal/metrics"
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+// lagState tracks a client whose send buffer filled up
+type lagState struct {
+	since   time.Time
+	dropped int
+}
+
+// backpressure applies the backpressure policies to clients that read
+// slower than messages arrive. It is guarded by the hub's mutex.
+type backpressure struct {
+	policy  models.BackpressurePolicy
+	grace   time.Duration
+	lagging map[*models.Client]*lagState
+}
+
+func newBackpressure(policy string, grace time.Duration) *backpressure {
+	p := models.ParseBackpressurePolicy(policy)
+	if p == "" {
+		slog.Warn("unknown slow client policy, using the default", "policy", policy, "default", models.DropLowPriority)
+		p = models.DropLowPriority
+	}
+	return &backpressure{
+		policy:  p,
+		grace:   grace,
+		lagging: make(map[*models.Client]*lagState),
+	}
+}
+
+// policyOf returns the policy applied to the client
+func (b *backpressure) policyOf(client *models.Client) models.BackpressurePolicy {
+	if client.Backpressure != "" {
+		return client.Backpressure
+	}
+	return b.policy
+}
+
+// trySend queues a message for a client that is still connected. When the
+// client's send buffer is full, its backpressure policy decides what is
+// skipped; a client that is still behind once the grace window is over is
+// disconnected. Callers must hold h.mu for writing.
+func (h *Hub) trySend(client *models.Client, message models.Message) {
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+
+	lag := h.slow.lagging[client]
+	if lag != nil && len(client.Send) <= cap(client.Send)/2 {
+		client.Log.Debug("slow client caught up", "dropped", lag.dropped)
+		delete(h.slow.lagging, client)
+		lag = nil
+	}
+
+	select {
+	case client.Send <- message:
+		return
+	default:
+	}
+
+	now := time.Now()
+	if lag == nil {
+		lag = &lagState{since: now}
+		h.slow.lagging[client] = lag
+		h.sendLagging(client, lag)
+	} else if now.Sub(lag.since) > h.slow.grace {
+		metrics.MessagesDropped.With(string(message.Type)).Inc()
+		metrics.SlowClientEvictions.Inc()
+		client.Log.Info("disconnecting slow client",
+			"behind_for", now.Sub(lag.since), "dropped", lag.dropped+1)
+		h.removeClient(client, reasonSlowClient)
+		return
+	}
+
+	switch policy := h.slow.policyOf(client); {
+	case policy == models.DisconnectSlow,
+		policy == models.DropLowPriority && message.Type.Droppable():
+		h.drop(lag, message)
+		return
+	}
+	h.makeRoom(client, lag)
+
+	select {
+	case client.Send <- message:
+	default:
+		h.drop(lag, message)
+	}
+}
+
+// sendLagging warns a client that just fell behind
+func (h *Hub) sendLagging(client *models.Client, lag *lagState) {
+	h.makeRoom(client, lag)
+	select {
+	case client.Send <- models.Message{
+		ID:        uuid.New().String(),
+		Type:      models.Lagging,
+		Room:      client.Room,
+		Content:   "You are falling behind, some messages are being skipped",
+		CreatedAt: time.Now(),
+	}:
+	default:
+	}
+}
+
+// makeRoom frees space in the client's send buffer if it is full,
+// dropping droppable messages first if that is the client's policy, or
+// else the oldest one
+func (h *Hub) makeRoom(client *models.Client, lag *lagState) {
+	if len(client.Send) < cap(client.Send) {
+		return
+	}
+	if h.slow.policyOf(client) == models.DropLowPriority && h.dropLowPriority(client, lag) {
+		return
+	}
+	h.dropOldest(client, lag)
+}
+
+func (h *Hub) drop(lag *lagState, message models.Message) {
+	lag.dropped++
+	metrics.MessagesDropped.With(string(message.Type)).Inc()
+}
+
+// dropOldest removes the message at the head of the client's send buffer
+func (h *Hub) dropOldest(client *models.Client, lag *lagState) {
+	select {
+	case message := <-client.Send:
+		h.drop(lag, message)
+	default:
+	}
+}
+
+// dropLowPriority removes the droppable messages from the client's send
+// buffer, keeping the others in order. It reports whether any were
+// removed. Only the hub sends on client.Send, and it holds h.mu, so the
+// messages put back always fit.
+func (h *Hub) dropLowPriority(client *models.Client, lag *lagState) bool {
+	queued := make([]models.Message, 0, len(client.Send))
+drain:
+	for len(queued) < cap(queued) {
+		select {
+		case message := <-client.Send:
+			queued = append(queued, message)
+		default:
+			break drain
+		}
+	}
+
+	dropped := false
+	for _, message := range queued {
+		if message.Type.Droppable() {
+			h.drop(lag, message)
+			dropped = true
+			continue
+		}
+		client.Send <- message
+	}
+	return dropped
+}
+
+// laggingClients returns the number of clients currently behind
+func (h *Hub) laggingClients() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.slow.lagging)
+}
@@ -0,0 +1,228 @@
+package hub
+
+import (
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+const testGrace = 10 * time.Second
+
+func text(id string) models.Message {
+	return models.Message{ID: id, Type: models.TextMessage}
+}
+
+func reaction(id string) models.Message {
+	return models.Message{ID: id, Type: models.Reaction}
+}
+
+func pollUpdate(id string) models.Message {
+	return models.Message{ID: id, Type: models.PollUpdate}
+}
+
+// queuedIDs drains a client's send buffer and returns the IDs of the
+// messages in it, with "lagging" standing for the lagging warning
+func queuedIDs(client *models.Client) []string {
+	var ids []string
+	for {
+		select {
+		case message, ok := <-client.Send:
+			if !ok {
+				return ids
+			}
+			if message.Type == models.Lagging {
+				ids = append(ids, "lagging")
+			} else {
+				ids = append(ids, message.ID)
+			}
+		default:
+			return ids
+		}
+	}
+}
+
+func TestTrySend(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy models.BackpressurePolicy
+		queued []models.Message
+		// behindFor is how long the client has been lagging, zero if it
+		// has not fallen behind yet
+		behindFor time.Duration
+		send      models.Message
+		want      []string
+		// wantDropped is the number of messages dropped since the client
+		// fell behind
+		wantDropped   int
+		wantLagging   bool
+		wantConnected bool
+	}{
+		{
+			name:          "buffer has room",
+			policy:        models.DropOldest,
+			queued:        []models.Message{text("t1")},
+			send:          text("t2"),
+			want:          []string{"t1", "t2"},
+			wantConnected: true,
+		},
+		{
+			name:          "drop_low_priority drops droppable queued messages",
+			policy:        models.DropLowPriority,
+			queued:        []models.Message{text("t1"), reaction("r1"), text("t2"), pollUpdate("p1")},
+			send:          text("t3"),
+			want:          []string{"t1", "t2", "lagging", "t3"},
+			wantDropped:   2,
+			wantLagging:   true,
+			wantConnected: true,
+		},
+		{
+			name:          "drop_low_priority drops the oldest without droppable messages",
+			policy:        models.DropLowPriority,
+			queued:        []models.Message{text("t1"), text("t2"), text("t3"), text("t4")},
+			send:          text("t5"),
+			want:          []string{"t3", "t4", "lagging", "t5"},
+			wantDropped:   2,
+			wantLagging:   true,
+			wantConnected: true,
+		},
+		{
+			name:          "drop_low_priority skips a droppable message",
+			policy:        models.DropLowPriority,
+			queued:        []models.Message{text("t1"), text("t2"), text("t3"), text("t4")},
+			behindFor:     time.Second,
+			send:          reaction("r1"),
+			want:          []string{"t1", "t2", "t3", "t4"},
+			wantDropped:   1,
+			wantLagging:   true,
+			wantConnected: true,
+		},
+		{
+			name:          "drop_oldest",
+			policy:        models.DropOldest,
+			queued:        []models.Message{text("t1"), text("t2"), text("t3"), text("t4")},
+			send:          text("t5"),
+			want:          []string{"t3", "t4", "lagging", "t5"},
+			wantDropped:   2,
+			wantLagging:   true,
+			wantConnected: true,
+		},
+		{
+			name:          "drop_oldest while lagging",
+			policy:        models.DropOldest,
+			queued:        []models.Message{reaction("r1"), text("t2"), text("t3"), text("t4")},
+			behindFor:     time.Second,
+			send:          text("t5"),
+			want:          []string{"t2", "t3", "t4", "t5"},
+			wantDropped:   1,
+			wantLagging:   true,
+			wantConnected: true,
+		},
+		{
+			name:          "disconnect skips new messages",
+			policy:        models.DisconnectSlow,
+			queued:        []models.Message{text("t1"), text("t2"), text("t3"), text("t4")},
+			send:          text("t5"),
+			want:          []string{"t2", "t3", "t4", "lagging"},
+			wantDropped:   2,
+			wantLagging:   true,
+			wantConnected: true,
+		},
+		{
+			name:          "disconnect within grace",
+			policy:        models.DisconnectSlow,
+			queued:        []models.Message{text("t1"), text("t2"), text("t3"), text("t4")},
+			behindFor:     testGrace - time.Second,
+			send:          text("t5"),
+			want:          []string{"t1", "t2", "t3", "t4"},
+			wantDropped:   1,
+			wantLagging:   true,
+			wantConnected: true,
+		},
+		{
+			name:      "disconnect after grace",
+			policy:    models.DisconnectSlow,
+			queued:    []models.Message{text("t1"), text("t2"), text("t3"), text("t4")},
+			behindFor: testGrace + time.Second,
+			send:      text("t5"),
+			want:      []string{"t1", "t2", "t3", "t4"},
+		},
+		{
+			name:      "drop_oldest disconnects after grace",
+			policy:    models.DropOldest,
+			queued:    []models.Message{text("t1"), text("t2"), text("t3"), text("t4")},
+			behindFor: testGrace + time.Second,
+			send:      text("t5"),
+			want:      []string{"t1", "t2", "t3", "t4"},
+		},
+		{
+			name:          "caught up after draining",
+			policy:        models.DisconnectSlow,
+			queued:        []models.Message{text("t1")},
+			behindFor:     testGrace + time.Second,
+			send:          text("t2"),
+			want:          []string{"t1", "t2"},
+			wantConnected: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &models.Client{
+				ID:   "c1",
+				Send: make(chan models.Message, 4),
+				Room: "live",
+				Log:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+			h := &Hub{
+				clients: map[*models.Client]bool{client: true},
+				rooms:   make(map[string]*Room),
+				slow:    newBackpressure(string(tc.policy), testGrace),
+			}
+			for _, message := range tc.queued {
+				client.Send <- message
+			}
+			if tc.behindFor > 0 {
+				h.slow.lagging[client] = &lagState{since: time.Now().Add(-tc.behindFor)}
+			}
+
+			h.trySend(client, tc.send)
+
+			lag, lagging := h.slow.lagging[client]
+			_, connected := h.clients[client]
+			if got := queuedIDs(client); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("send buffer = %v, want %v", got, tc.want)
+			}
+			if connected != tc.wantConnected {
+				t.Fatalf("connected = %v, want %v", connected, tc.wantConnected)
+			}
+			if lagging != tc.wantLagging {
+				t.Fatalf("lagging = %v, want %v", lagging, tc.wantLagging)
+			}
+			if lagging && lag.dropped != tc.wantDropped {
+				t.Errorf("dropped = %d, want %d", lag.dropped, tc.wantDropped)
+			}
+			if !connected {
+				if _, ok := <-client.Send; ok {
+					t.Error("send buffer of a disconnected client is still open")
+				}
+			}
+		})
+	}
+}
+
+func TestTrySendToDisconnectedClient(t *testing.T) {
+	client := &models.Client{ID: "c1", Send: make(chan models.Message, 1)}
+	h := &Hub{
+		clients: make(map[*models.Client]bool),
+		slow:    newBackpressure(string(models.DropOldest), testGrace),
+	}
+
+	h.trySend(client, text("t1"))
+	if got := queuedIDs(client); got != nil {
+		t.Errorf("send buffer = %v, want it empty", got)
+	}
+}
@@ -0,0 +1,124 @@
+package hub
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+const (
+	// eventKeyPrefix prefixes the Redis keys recording idempotency keys of
+	// ingested events. The value is the published message ID, or
+	// eventPending while the event is being handled.
+	eventKeyPrefix = "pollz:event:"
+	eventPending   = "pending"
+
+	// eventKeyTTL is how long retries of a handled event are recognised
+	eventKeyTTL = 24 * time.Hour
+
+	// eventPendingTTL bounds how long an event stays claimed, so an event
+	// whose handling was cut short can be retried
+	eventPendingTTL = 30 * time.Second
+
+	maxIdempotencyKeyLength = 128
+)
+
+// eventNamespace derives message IDs from idempotency keys, so even an
+// event replayed after its key expired is stored only once
+var eventNamespace = uuid.MustParse("6f1c2a1e-4d5b-4c8e-9a3f-7b2d0e9c8a41")
+
+// IngestEvent publishes an event pushed by the backend. Events whose
+// idempotency key was already handled are not published again; the result
+// then carries the original message ID.
+func (h *Hub) IngestEvent(event models.Event) (models.EventResult, error) {
+	if err := validateEvent(event); err != nil {
+		return models.EventResult{}, err
+	}
+
+	ctx := context.Background()
+	key := eventKeyPrefix + event.IdempotencyKey
+	claimed, err := h.redis.SetNX(ctx, key, eventPending, eventPendingTTL).Result()
+	if err != nil {
+		return models.EventResult{}, fmt.Errorf("failed to record idempotency key: %w", err)
+	}
+	if !claimed {
+		messageID, err := h.redis.Get(ctx, key).Result()
+		if err != nil {
+			return models.EventResult{}, fmt.Errorf("failed to look up idempotency key: %w", err)
+		}
+		if messageID == eventPending {
+			return models.EventResult{}, models.ErrEventInProgress
+		}
+		return models.EventResult{MessageID: messageID, Duplicate: true}, nil
+	}
+
+	messageID, duplicate, err := h.publishEvent(event)
+	if err != nil {
+		// Let the backend retry
+		h.redis.Del(ctx, key)
+		return models.EventResult{}, err
+	}
+	if err := h.redis.Set(ctx, key, messageID, eventKeyTTL).Err(); err != nil {
+		return models.EventResult{}, fmt.Errorf("failed to record idempotency key: %w", err)
+	}
+	return models.EventResult{MessageID: messageID, Duplicate: duplicate}, nil
+}
+
+func validateEvent(event models.Event) error {
+	key := event.IdempotencyKey
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return fmt.Errorf("%w: idempotency_key is required and at most %d bytes", models.ErrInvalidInput, maxIdempotencyKeyLength)
+	}
+
+	switch event.Type {
+	case models.EventSystem, models.EventAnnouncement, models.EventSuperChat:
+		if !models.ValidRoom(event.Room) {
+			return fmt.Errorf("%w: invalid room %q", models.ErrInvalidInput, event.Room)
+		}
+		if strings.TrimSpace(event.Message) == "" && event.Type != models.EventSuperChat {
+			return fmt.Errorf("%w: empty message", models.ErrInvalidInput)
+		}
+		if event.Type == models.EventSuperChat {
+			return validateSuperChat(event)
+		}
+	case models.EventPollResults:
+		if event.Poll == nil {
+			return fmt.Errorf("%w: poll_results events need a poll", models.ErrInvalidInput)
+		}
+	default:
+		return fmt.Errorf("%w: unknown event type %q", models.ErrInvalidInput, event.Type)
+	}
+	return nil
+}
+
+// publishEvent publishes a validated event and returns the ID of the
+// message it produced, if any, and whether that message already existed
+func (h *Hub) publishEvent(event models.Event) (string, bool, error) {
+	if event.Type == models.EventPollResults {
+		return "", false, h.PublishPollTally(*event.Poll)
+	}
+
+	message := models.Message{
+		ID:        uuid.NewSHA1(eventNamespace, []byte(event.IdempotencyKey)).String(),
+		Content:   event.Message,
+		Room:      event.Room,
+		UserID:    event.UserID,
+		Username:  event.Username,
+		CreatedAt: time.Now(),
+	}
+	switch event.Type {
+	case models.EventSystem:
+		message.Type = models.SystemMessage
+	case models.EventAnnouncement:
+		message.Type = models.Announcement
+	case models.EventSuperChat:
+		return h.publishSuperChat(message, event)
+	}
+
+	h.Broadcast(message)
+	return message.ID, false, nil
+}
@@ -0,0 +1,83 @@
+package hub
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+// defaultHistoryPage is the page size used when a request sets none
+const defaultHistoryPage = 50
+
+// GetHistory returns a page of the room's messages before or after a
+// cursor. Without a cursor it returns the newest messages.
+func (h *Hub) GetHistory(room, before, after string, limit int) (models.HistoryPage, error) {
+	if !models.ValidRoom(room) {
+		return models.HistoryPage{}, fmt.Errorf("%w: invalid room %q", models.ErrInvalidInput, room)
+	}
+	if before != "" && after != "" {
+		return models.HistoryPage{}, fmt.Errorf("%w: use either before or after", models.ErrInvalidInput)
+	}
+	if limit <= 0 {
+		limit = defaultHistoryPage
+	}
+	if limit > models.MaxHistoryPage {
+		limit = models.MaxHistoryPage
+	}
+
+	var cursor *models.Cursor
+	if token := before + after; token != "" {
+		c, err := models.ParseCursor(token)
+		if err != nil {
+			return models.HistoryPage{}, err
+		}
+		cursor = &c
+	}
+
+	messages, more, err := h.messageRepo.GetPage(room, cursor, after != "", limit)
+	if err != nil {
+		return models.HistoryPage{}, err
+	}
+	h.attachReactions(messages)
+
+	page := models.HistoryPage{Room: room, Messages: messages}
+	if more {
+		next := messages[0]
+		if after != "" {
+			next = messages[len(messages)-1]
+		}
+		page.NextCursor = models.CursorOf(next).String()
+	}
+	return page, nil
+}
+
+// handleHistoryRequest sends the client an older page of its current room
+func (h *Hub) handleHistoryRequest(client *models.Client, message models.Message) {
+	if !h.allowHistory(client) {
+		return
+	}
+
+	h.mu.RLock()
+	room := client.Room
+	h.mu.RUnlock()
+
+	page, err := h.GetHistory(room, message.Before, "", message.Limit)
+	if errors.Is(err, models.ErrInvalidInput) {
+		h.reject(client, &models.FrameError{Code: models.ErrCodeInvalidField, Message: err.Error()})
+		return
+	}
+	if err != nil {
+		client.Log.Error("failed to get history", "room", room, "error", err)
+		h.notify(client, "Could not load older messages, please try again")
+		return
+	}
+
+	h.sendDirect(client, models.Message{
+		Type:      models.History,
+		Room:      room,
+		History:   &page,
+		CreatedAt: time.Now(),
+	})
+}
@@ -3,316 +3,485 @@ package hub
 import (
 	"database/sql"
 	"encoding/json"
-	"log"
-	"strings"
+	"fmt"
+	"log/slog"
 	"sync"
 	"time"
 
-	"unicode"
-
 	"github.com/google/uuid"
 	"github.com/pollz/websocket-server/internal/cache"
+	"github.com/pollz/websocket-server/internal/config"
+	"github.com/pollz/websocket-server/internal/filter"
+	"github.com/pollz/websocket-server/internal/logging"
+	"github.com/pollz/websocket-server/internal/metrics"
 	"github.com/pollz/websocket-server/internal/models"
+	"github.com/pollz/websocket-server/internal/persistence"
 	"github.com/pollz/websocket-server/internal/repository"
 	"github.com/redis/go-redis/v9"
 )
 
-type TrieNode struct {
-	children map[rune]*TrieNode
-	isEnd    bool
-}
-
-// Trie structure
-type Trie struct {
-	root *TrieNode
+type Hub struct {
+	clients         map[*models.Client]bool
+	rooms           map[string]*Room
+	broadcast       chan models.Message
+	register        chan *models.Client
+	unregister      chan *models.Client
+	join            chan roomChange
+	mu              sync.RWMutex
+	filter          *filter.Filter
+	profanityRepo   *repository.ProfanityRepository
+	messageRepo     *repository.MessageRepository
+	reactionRepo    *repository.ReactionRepository
+	moderationRepo  *repository.ModerationRepository
+	superChatRepo   *repository.SuperChatRepository
+	stickerRepo     *repository.StickerRepository
+	stickers        *stickerCatalog
+	bans            *banList
+	polls           *pollSubscriptions
+	presence        *presence
+	typing          *typingState
+	messageCache    *cache.MessageCache
+	writer          *persistence.Writer
+	limiter         *rateLimiter
+	slow            *backpressure
+	roomIdleTimeout time.Duration
+
+	// Replay buffers for clients resuming after a reconnect
+	replayBufferSize int
+	replayTTL        time.Duration
+
+	// Cross-instance fan-out over Redis pub/sub
+	redis  *redis.Client
+	nodeID string
+	remote chan models.Message
+	outbox chan models.Message
+	seen   *dedupSet
+
+	// unsequenced holds accepted broadcasts until runSequencer numbers
+	// and fans them out
+	unsequenced chan models.Message
+
+	// Lifecycle: stop asks Run to disconnect everyone and return, done is
+	// closed once it has, pending tracks writes that must finish first
+	stop    chan chan struct{}
+	done    chan struct{}
+	pending sync.WaitGroup
 }
 
-// Create new Trie
-func NewTrie() *Trie {
-	return &Trie{root: &TrieNode{children: make(map[rune]*TrieNode)}}
+// roomChange asks the hub to move a client to another room
+type roomChange struct {
+	client *models.Client
+	room   string
 }
 
-// Insert a word into the Trie
-func (t *Trie) Insert(word string) {
-	w := strings.ToLower(strings.TrimSpace(word))
-	if w == "" {
-		return
+func New(cfg *config.Config, redisClient *redis.Client, db *sql.DB) *Hub {
+	profanityRepo := repository.NewProfanityRepository(db)
+	censor := filter.New(profanityRepo)
+	if err := censor.Reload(); err != nil {
+		slog.Error("failed to load profanity word list", "error", err)
 	}
-	node := t.root
-	for _, ch := range w {
-		if node.children[ch] == nil {
-			node.children[ch] = &TrieNode{children: make(map[rune]*TrieNode)}
-		}
-		node = node.children[ch]
-	}
-	node.isEnd = true
-}
 
-// Search checks if the word exists in the Trie
-func (t *Trie) Search(word string) bool {
-	node := t.root
-	for _, ch := range word {
-		if node.children[ch] == nil {
-			return false
-		}
-		node = node.children[ch]
+	nodeID := cfg.NodeID
+	if nodeID == "" {
+		nodeID = uuid.New().String()
 	}
-	return node.isEnd
-}
 
-type Hub struct {
-	clients      map[*models.Client]bool
-	broadcast    chan models.Message
-	register     chan *models.Client
-	unregister   chan *models.Client
-	mu           sync.RWMutex
-	tri          *Trie
-	messageRepo  *repository.MessageRepository
-	messageCache *cache.MessageCache
-}
+	messageRepo := repository.NewMessageRepository(db)
+
+	stickerRepo := repository.NewStickerRepository(db)
+	stickers := newStickerCatalog(stickerRepo)
+	if err := stickers.reload(); err != nil {
+		slog.Error("failed to load sticker catalog", "error", err)
+	}
 
-func New(redisClient *redis.Client, db *sql.DB) *Hub {
-	words := []string{
-		"aad", "aand", "bahenchod", "behenchod", "bhenchod", "bhenchodd", "b.c.", "bc",
-		"bakchod", "bakchodd", "bakchodi", "bevda", "bewda", "bevdey", "bewday", "bevakoof",
-		"bevkoof", "bevkuf", "bewakoof", "bewkoof", "bewkuf", "bhadua", "bhaduaa", "bhadva",
-		"bhadvaa", "bhadwa", "bhadwaa", "bhosada", "bhosda", "bhosdaa", "bhosdike", "bhonsdike",
-		"bsdk", "b.s.d.k", "bhosdiki", "bhosdiwala", "bhosdiwale", "bhosadchodal", "bhosadchod",
-		"babbe", "babbey", "bube", "bubey", "bur", "burr", "buurr", "buur", "charsi", "chooche",
-		"choochi", "chuchi", "chhod", "chod", "chodd", "chudne", "chudney", "chudwa", "chudwaa",
-		"chudwane", "chudwaane", "choot", "chut", "chute", "chutia", "chutiya", "chutiye",
-		"chuttad", "chutad", "dalaal", "dalal", "dalle", "dalley", "fattu", "gadha", "gadhe",
-		"gadhalund", "gaand", "gand", "gandu", "gandfat", "gandfut", "gandiya", "gandiye", "goo",
-		"gu", "gote", "gotey", "gotte", "hag", "haggu", "hagne", "hagney", "harami", "haramjada",
-		"haraamjaada", "haramzyada", "haraamzyaada", "haraamjaade", "haraamzaade", "haraamkhor",
-		"haramkhor", "jhat", "jhaat", "jhaatu", "jhatu", "kutta", "kutte", "kuttey", "kutia",
-		"kutiya", "kuttiya", "kutti", "landi", "landy", "laude", "laudey", "laura", "lora",
-		"lauda", "ling", "loda", "lode", "lund", "launda", "lounde", "laundey", "laundi", "loundi",
-		"laundiya", "loundiya", "lulli", "maar", "maro", "marunga", "madarchod", "madarchodd",
-		"madarchood", "madarchoot", "madarchut", "m.c.", "mc", "mamme", "mammey", "moot", "mut",
-		"mootne", "mutne", "mooth", "muth", "nunni", "nunnu", "paaji", "paji", "pesaab", "pesab",
-		"peshaab", "peshab", "pilla", "pillay", "pille", "pilley", "pisaab", "pisab", "pkmkb",
-		"porkistan", "raand", "rand", "randi", "randy", "suar", "tatte", "tatti", "tatty", "ullu", "SU", "zero",
-		"anuj", "wagh", "sajal", "yadav", "aditya", "khandelwal", "nepali", "daksh", "tyagi", "apoorv", "singh", "gurgaon", "tarang", "agrawal", "test",
-	}
-	trie := NewTrie()
-
-	// Insert words into Trie
-	for _, w := range words {
-		trie.Insert(w)
-	}
-
-	return &Hub{
-		clients:      make(map[*models.Client]bool),
-		broadcast:    make(chan models.Message, 256),
-		register:     make(chan *models.Client),
-		unregister:   make(chan *models.Client),
-		tri:          trie,
-		messageRepo:  repository.NewMessageRepository(db),
-		messageCache: cache.NewMessageCache(redisClient),
+	h := &Hub{
+		clients:        make(map[*models.Client]bool),
+		rooms:          make(map[string]*Room),
+		broadcast:      make(chan models.Message, 256),
+		register:       make(chan *models.Client),
+		unregister:     make(chan *models.Client),
+		join:           make(chan roomChange),
+		filter:         censor,
+		profanityRepo:  profanityRepo,
+		messageRepo:    messageRepo,
+		reactionRepo:   repository.NewReactionRepository(db),
+		moderationRepo: repository.NewModerationRepository(db),
+		superChatRepo:  repository.NewSuperChatRepository(db),
+		stickerRepo:    stickerRepo,
+		stickers:       stickers,
+		bans:           newBanList(),
+		polls:          newPollSubscriptions(cfg.PollUpdateInterval),
+		presence:       newPresence(cfg.PresenceInterval),
+		typing:         newTypingState(),
+		messageCache:   cache.NewMessageCache(redisClient),
+		writer: persistence.NewWriter(messageRepo, persistence.Options{
+			QueueSize:     cfg.PersistQueueSize,
+			BatchSize:     cfg.PersistBatchSize,
+			FlushInterval: cfg.PersistFlushInterval,
+			MaxRetries:    cfg.PersistMaxRetries,
+			JournalPath:   cfg.JournalPath,
+		}),
+		roomIdleTimeout:  cfg.RoomIdleTimeout,
+		replayBufferSize: cfg.ReplayBufferSize,
+		replayTTL:        cfg.ReplayTTL,
+		limiter:          newRateLimiter(cfg),
+		slow:             newBackpressure(cfg.SlowClientPolicy, cfg.SlowClientGrace),
+		redis:            redisClient,
+		nodeID:           nodeID,
+		remote:           make(chan models.Message, 256),
+		unsequenced:      make(chan models.Message, 1024),
+		outbox:           make(chan models.Message, 256),
+		seen:             newDedupSet(relayDedupTTL),
+		stop:             make(chan chan struct{}),
+		done:             make(chan struct{}),
 	}
+	h.registerMetrics()
+	return h
 }
 
 func (h *Hub) Run() {
-	// Start cleanup routine
+	// Start cleanup routines
 	go h.startCleanupRoutine()
+	go h.startRoomJanitor()
+	h.loadSlowModes()
+	h.loadBans()
+	go h.startRelay()
+	go h.startPollFlusher()
+	go h.startPresence()
+	h.writer.Start()
+	h.pending.Add(1)
+	go h.runPublisher()
+	h.pending.Add(1)
+	go h.runSequencer()
 
 	for {
 		select {
+		case stopped := <-h.stop:
+			h.handleShutdown()
+			close(h.done)
+			close(stopped)
+			return
+
 		case client := <-h.register:
 			h.handleRegister(client)
 
 		case client := <-h.unregister:
 			h.handleUnregister(client)
 
+		case change := <-h.join:
+			h.handleJoin(change)
+
 		case message := <-h.broadcast:
 			h.handleBroadcast(message)
+
+		case message := <-h.remote:
+			h.handleRemote(message)
 		}
 	}
 }
 
 func (h *Hub) Register(client *models.Client) {
-	h.register <- client
+	select {
+	case h.register <- client:
+	case <-h.done:
+	}
 }
 
 func (h *Hub) Unregister(client *models.Client) {
-	h.unregister <- client
+	select {
+	case h.unregister <- client:
+	case <-h.done:
+	}
 }
 
+// Broadcast sends a server-originated message to the room named in the
+// message, or to the default room if none is set.
 func (h *Hub) Broadcast(message models.Message) {
-	h.broadcast <- message
+	select {
+	case h.broadcast <- message:
+	case <-h.done:
+	}
 }
 
-func (h *Hub) handleRegister(client *models.Client) {
-	h.mu.Lock()
-	h.clients[client] = true
-	clientCount := len(h.clients)
-	h.mu.Unlock()
-
-	// Send recent messages to new client
-	messages, err := h.getRecentMessages()
-	if err != nil {
-		log.Printf("Error getting recent messages: %v", err)
-		messages = []models.Message{}
+// Receive handles a frame read from a client connection. Frames that do not
+// match the schema of their type are answered with an error frame. Join
+// frames move the client to another room, reaction frames update a
+// message's reactions, moderation frames run moderator commands, poll frames
+// manage poll subscriptions, history requests return older messages, typing
+// frames are relayed to the room, and text and sticker messages are
+// broadcast to the client's current room.
+func (h *Hub) Receive(client *models.Client, message models.Message) {
+	if err := h.validateFrame(client, message); err != nil {
+		metrics.FramesRejected.With(err.Code).Inc()
+		h.reject(client, err)
+		return
 	}
+	metrics.MessagesReceived.With(string(message.Type)).Inc()
 
-	response := models.RecentMessagesResponse{
-		Type:     "recent_messages",
-		Messages: messages,
-	}
+	switch message.Type {
+	case models.JoinRoom:
+		select {
+		case h.join <- roomChange{client: client, room: message.Room}:
+		case <-h.done:
+		}
+
+	case models.Reaction:
+		h.handleReaction(client, message)
+
+	case models.Moderation:
+		h.handleModeration(client, message)
+
+	case models.PollSubscribe:
+		h.subscribePoll(client, message.PollID)
+
+	case models.PollUnsubscribe:
+		h.polls.unsubscribe(client, message.PollID)
+
+	case models.HistoryRequest:
+		h.handleHistoryRequest(client, message)
+
+	case models.TypingStart, models.TypingStop:
+		h.handleTyping(client, message)
 
-	// Send as a special message type
-	select {
-	case client.Send <- models.Message{
-		Type:    "recent_messages",
-		Content: mustMarshalString(response),
-	}:
 	default:
-		// Client's send channel is full, close it
+		if client.ReadOnly {
+			h.notify(client, "Sign in to send messages")
+			return
+		}
+		// Keep only the fields clients may set on chat messages; the rest,
+		// including the ID, is filled in by the server
+		message = models.Message{
+			ID:        uuid.New().String(),
+			Type:      message.Type,
+			Content:   message.Content,
+			ReplyTo:   message.ReplyTo,
+			UserID:    message.UserID,
+			Username:  message.Username,
+			CreatedAt: message.CreatedAt,
+		}
+		h.mu.RLock()
+		message.Room = client.Room
+		h.mu.RUnlock()
+		if !h.allowMessage(client, message.Room) {
+			return
+		}
+		if message.ReplyTo != "" && !h.attachReplyPreview(client, &message) {
+			return
+		}
+		h.stopTyping(client)
+		h.Broadcast(message)
+	}
+}
+
+func (h *Hub) handleRegister(client *models.Client) {
+	if h.bans.banned(client.UserID, client.IP, time.Now()) {
+		// The client was never added, so closing Send here is the only close
+		client.CloseCode = models.CloseBanned
+		client.CloseReason = "banned"
 		close(client.Send)
-		delete(h.clients, client)
+		metrics.Disconnections.With("banned").Inc()
+		client.Log.Info("rejected banned client", "user_id", client.UserID, logging.IPKey, client.IP)
+		return
 	}
 
-	log.Printf("Client %s connected. Total: %d", client.ID, clientCount)
+	h.mu.Lock()
+	if !models.ValidRoom(client.Room) {
+		client.Room = models.DefaultRoom
+	}
+	h.clients[client] = true
+	h.room(client.Room).clients[client] = true
+	room := client.Room
+	clientCount := len(h.clients)
+	h.mu.Unlock()
+	metrics.Connections.Inc()
+
+	go h.catchUp(client, room, client.LastSeq, client.Polls)
+	h.joinPresence(client, room)
+
+	client.Log.Info("client connected", "room", room, "user_id", client.UserID, "clients", clientCount)
 }
 
 func (h *Hub) handleUnregister(client *models.Client) {
 	h.mu.Lock()
 	if _, ok := h.clients[client]; ok {
-		delete(h.clients, client)
-		close(client.Send)
+		h.removeClient(client, reasonClientClosed)
 		clientCount := len(h.clients)
 		h.mu.Unlock()
-		log.Printf("Client %s disconnected. Total: %d", client.ID, clientCount)
+		client.Log.Info("client disconnected", "clients", clientCount)
 	} else {
 		h.mu.Unlock()
 	}
+	h.limiter.forget(client)
+	h.polls.forget(client)
+	if room, ok := h.typing.forget(client); ok {
+		h.handleBroadcast(typingFrame(models.TypingStop, client, room))
+	}
+	h.leavePresence(client)
 }
 
-func (h *Hub) removeBad(content string) string {
-	log.Printf("removeBad called with content: '%s'", content)
-	if h.tri == nil || content == "" {
-		log.Printf("Trie is nil or content empty, returning original: '%s'", content)
-		return content
-	}
-	
-	// Add safety check to prevent crashes
-	var result string
-	defer func() {
-		if r := recover(); r != nil {
-			log.Printf("Error in removeBad: %v", r)
-			// On panic, still try to return censored content if possible
-			result = "***"
-		}
-	}()
-	
-	// First pass: Check normal words
-	var b strings.Builder
-	token := make([]rune, 0, 32)
-	
-	flush := func() {
-		if len(token) == 0 {
-			return
-		}
-		word := string(token)
-		norm := strings.ToLower(word)
-		log.Printf("Checking word: '%s' (normalized: '%s')", word, norm)
-		if h.tri.Search(norm) {
-			log.Printf("Word '%s' is censored -> ***", word)
-			b.WriteString("***")
-		} else {
-			log.Printf("Word '%s' is allowed", word)
-			b.WriteString(word)
-		}
-		token = token[:0]
-	}
-	
-	for _, r := range content {
-		if unicode.IsLetter(r) || unicode.IsDigit(r) {
-			token = append(token, r)
-		} else {
-			flush()
-			b.WriteRune(r) // preserve original punctuation/whitespace
-		}
+func (h *Hub) handleJoin(change roomChange) {
+	client := change.client
+
+	h.mu.Lock()
+	if _, ok := h.clients[client]; !ok || client.Room == change.room {
+		h.mu.Unlock()
+		return
+	}
+	if old, ok := h.rooms[client.Room]; ok {
+		delete(old.clients, client)
+		old.lastActive = time.Now()
+	}
+	client.Room = change.room
+	h.room(change.room).clients[client] = true
+	h.mu.Unlock()
+
+	if room, ok := h.typing.stop(client); ok {
+		h.handleBroadcast(typingFrame(models.TypingStop, client, room))
 	}
-	flush()
-	
-	result = b.String()
-	
-	// Second pass: Check for spaced-out words (like "b s d k" -> "bsdk")
-	// Only do this check if the content is reasonable length to avoid issues
-	if len(result) > 0 && len(result) < 500 {
-		result = h.checkSpacedWords(result)
-	}
-	
-	log.Printf("removeBad returning: '%s'", result)
-	return result
+	h.leavePresence(client)
+	go h.catchUp(client, change.room, 0, nil)
+	h.joinPresence(client, change.room)
+
+	client.Log.Debug("client joined room", "room", change.room)
 }
 
-// checkSpacedWords detects words that are spaced out to bypass filtering
-func (h *Hub) checkSpacedWords(content string) string {
-	if h.tri == nil {
-		return content
+// removeClient drops the client from the hub and its room and closes its
+// send channel, recording why. Callers must hold h.mu for writing.
+func (h *Hub) removeClient(client *models.Client, reason string) {
+	if r, ok := h.rooms[client.Room]; ok {
+		delete(r.clients, client)
+		r.lastActive = time.Now()
 	}
-	
-	// Add safety check to prevent crashes
-	defer func() {
-		if r := recover(); r != nil {
-			log.Printf("Error in checkSpacedWords: %v", r)
-		}
-	}()
-	
-	words := strings.Fields(content)
-	if len(words) < 3 { // Require at least 3 words to avoid false positives
-		return content
-	}
-	
-	result := make([]string, len(words))
-	copy(result, words)
-	
-	// Check for patterns like "b s d k" (single characters with spaces)
-	for i := 0; i < len(words)-2; i++ { // Need at least 3 characters
-		// Look for sequences of single characters
-		var sequence []string
-		var indices []int
-		
-		j := i
-		for j < len(words) && len(strings.TrimSpace(words[j])) == 1 && unicode.IsLetter(rune(words[j][0])) {
-			sequence = append(sequence, strings.ToLower(strings.TrimSpace(words[j])))
-			indices = append(indices, j)
-			j++
+	delete(h.clients, client)
+	delete(h.slow.lagging, client)
+	close(client.Send)
+	metrics.Disconnections.With(reason).Inc()
+}
+
+// disconnect closes a client's connection with the given close code. It is
+// safe to call from any goroutine.
+func (h *Hub) disconnect(client *models.Client, code int, reason string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+	client.CloseCode = code
+	client.CloseReason = reason
+	h.removeClient(client, closeReason(code))
+	client.Log.Info("client disconnected by server", "reason", reason)
+}
+
+// notify sends a system message to a single client
+func (h *Hub) notify(client *models.Client, text string) {
+	h.sendDirect(client, models.Message{
+		Type:    models.SystemMessage,
+		Content: text,
+	})
+}
+
+// Reject sends the client an error frame for a frame that could not be read
+func (h *Hub) Reject(client *models.Client, code, text string) {
+	h.reject(client, &models.FrameError{Code: code, Message: text})
+}
+
+func (h *Hub) reject(client *models.Client, err *models.FrameError) {
+	h.sendDirect(client, models.Message{
+		Type:    models.ErrorMessage,
+		Code:    err.Code,
+		Content: err.Message,
+	})
+}
+
+// sendRecentMessages sends the room's recent history to a client that has
+// just connected to or joined it.
+// catchUp sends a client that connected to or joined a room the broadcasts
+// it missed since lastSeq, or a snapshot of the room, and the latest
+// tallies of the polls it follows. It reads Redis and the database, so it
+// runs on its own goroutine rather than holding up the hub.
+func (h *Hub) catchUp(client *models.Client, room string, lastSeq int64, polls []string) {
+	if lastSeq == 0 || !h.resume(client, room, lastSeq) {
+		if lastSeq != 0 {
+			h.sendInRoom(client, room, models.Message{Type: models.ResumeGap, Room: room})
 		}
-		
-		// Only check if we have at least 4 single characters to reduce false positives
-		if len(sequence) >= 4 {
-			combined := strings.Join(sequence, "")
-			if len(combined) >= 4 && h.tri.Search(combined) {
-				// Replace all the spaced characters with ***
-				for _, idx := range indices {
-					result[idx] = ""
-				}
-				if len(indices) > 0 {
-					result[indices[0]] = "***"
-				}
-			}
+		h.sendRecentMessages(client, room)
+	}
+	for _, pollID := range polls {
+		h.subscribePoll(client, pollID)
+	}
+}
+
+func (h *Hub) sendRecentMessages(client *models.Client, room string) {
+	messages, err := h.getRecentMessages(room)
+	if err != nil {
+		slog.Error("failed to get recent messages", "room", room, "error", err)
+		messages = []models.Message{}
+	}
+	h.attachReactions(messages)
+
+	snapshot := &models.RoomSnapshot{
+		Messages:   messages,
+		Pinned:     h.pinnedMessage(room),
+		SuperChats: h.pinnedSuperChats(room),
+	}
+	if len(messages) > 0 {
+		snapshot.NextCursor = models.CursorOf(messages[0]).String()
+	}
+	if seq, err := h.currentSeq(room); err == nil {
+		snapshot.Seq = seq
+	}
+
+	h.sendInRoom(client, room, models.Message{
+		Type:     models.RecentMessages,
+		Room:     room,
+		Snapshot: snapshot,
+	})
+}
+
+// sendInRoom sends messages to a client unless it left room meanwhile, so
+// a snapshot built while it switched rooms is not delivered. It is safe to
+// call from any goroutine.
+func (h *Hub) sendInRoom(client *models.Client, room string, messages ...models.Message) {
+	now := time.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if client.Room != room {
+		return
+	}
+	for _, message := range messages {
+		if message.ID == "" {
+			message.ID = uuid.New().String()
 		}
-		
-		// Skip ahead to avoid overlapping checks
-		if j > i+1 {
-			i = j - 2
+		if message.CreatedAt.IsZero() {
+			message.CreatedAt = now
 		}
+		h.trySend(client, message)
 	}
-	
-	// Filter out empty strings and join
-	var filtered []string
-	for _, word := range result {
-		if word != "" {
-			filtered = append(filtered, word)
-		}
+}
+
+// sendDirect sends a message to a single client if it is still connected.
+// It is safe to call from any goroutine.
+func (h *Hub) sendDirect(client *models.Client, message models.Message) {
+	if message.ID == "" {
+		message.ID = uuid.New().String()
+	}
+	if message.CreatedAt.IsZero() {
+		message.CreatedAt = time.Now()
 	}
-	
-	return strings.Join(filtered, " ")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.trySend(client, message)
 }
+
+func (h *Hub) removeBad(room, content string) string {
+	censored := h.filter.Censor(room, content)
+	if censored != content {
+		metrics.CensoredMessages.Inc()
+	}
+	return censored
+}
+
 func (h *Hub) handleBroadcast(message models.Message) {
 	// Ensure message has an ID
 	if message.ID == "" {
@@ -324,63 +493,99 @@ func (h *Hub) handleBroadcast(message models.Message) {
 		message.CreatedAt = time.Now()
 	}
 
-	// Save message asynchronously
-	message.Content = h.removeBad(message.Content)
-	go h.saveMessage(message)
+	if message.Room == "" {
+		message.Room = models.DefaultRoom
+	}
+	metrics.MessagesBroadcast.With(string(message.Type)).Inc()
 
-	// Broadcast to all connected clients
-	h.mu.RLock()
-	for client := range h.clients {
-		select {
-		case client.Send <- message:
-		default:
-			// Client's send channel is full, close it
-			close(client.Send)
-			delete(h.clients, client)
-		}
+	// Ephemeral messages are neither numbered nor stored
+	if message.Type.Ephemeral() {
+		h.fanOut(message)
+		return
 	}
-	h.mu.RUnlock()
-}
 
-func (h *Hub) saveMessage(msg models.Message) {
-	// Save to cache
-	if err := h.messageCache.Push(msg); err != nil {
-		log.Printf("Error saving to cache: %v", err)
+	// Events are already stored and carry no text. Other messages are
+	// censored before anything, including the replay buffer, sees them.
+	if !message.Type.IsEvent() {
+		message.Content = h.removeBad(message.Room, message.Content)
 	}
+	h.seen.add(message.ID, time.Now())
+	h.unsequenced <- message
+}
 
-	// Save to database
-	if err := h.messageRepo.Save(msg); err != nil {
-		log.Printf("Error saving to database: %v", err)
+// fanOut delivers a message to this instance's clients and relays it to
+// the other instances
+func (h *Hub) fanOut(message models.Message) {
+	h.seen.add(message.ID, time.Now())
+	h.deliver(message)
+	h.publish(message)
+}
+
+// deliver sends a message to this instance's clients in the message's
+// room. It holds h.mu for writing, as slow clients may be evicted while the
+// room is iterated.
+func (h *Hub) deliver(message models.Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if r, ok := h.rooms[message.Room]; ok {
+		r.lastActive = time.Now()
+		for client := range r.clients {
+			h.trySend(client, message)
+		}
 	}
 }
 
-func (h *Hub) getRecentMessages() ([]models.Message, error) {
+func (h *Hub) getRecentMessages(room string) ([]models.Message, error) {
 	// Try cache first
-	messages, err := h.messageCache.GetRecent(100)
+	messages, err := h.messageCache.GetRecent(room, 100)
 	if err == nil && len(messages) > 0 {
 		return messages, nil
 	}
 
 	// Fallback to database
-	messages, err = h.messageRepo.GetRecent(100)
+	messages, err = h.messageRepo.GetRecent(room, 100)
 	if err != nil {
 		return nil, err
 	}
 
 	// Repopulate cache
 	if len(messages) > 0 {
-		go h.messageCache.Populate(messages)
+		go h.messageCache.Populate(room, messages)
 	}
 
 	return messages, nil
 }
 
-func (h *Hub) SearchMessages(query string, limit int) ([]models.Message, error) {
-	return h.messageRepo.Search(query, limit)
+// SearchMessages runs a full-text search. Empty filters match all
+// messages.
+func (h *Hub) SearchMessages(q models.SearchQuery) (models.SearchPage, error) {
+	if q.Room != "" && !models.ValidRoom(q.Room) {
+		return models.SearchPage{}, fmt.Errorf("%w: invalid room %q", models.ErrInvalidInput, q.Room)
+	}
+	if !q.Since.IsZero() && !q.Until.IsZero() && !q.Since.Before(q.Until) {
+		return models.SearchPage{}, fmt.Errorf("%w: since must be before until", models.ErrInvalidInput)
+	}
+	if q.Limit <= 0 {
+		q.Limit = 20
+	}
+	if q.Limit > models.MaxSearchPage {
+		q.Limit = models.MaxSearchPage
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+	return h.messageRepo.Search(q)
+}
+
+// GetMessagesByDateRange returns messages in [start, end]. An empty room
+// matches all rooms.
+func (h *Hub) GetMessagesByDateRange(room string, start, end time.Time) ([]models.Message, error) {
+	return h.messageRepo.GetByDateRange(room, start, end)
 }
 
-func (h *Hub) GetMessagesByDateRange(start, end time.Time) ([]models.Message, error) {
-	return h.messageRepo.GetByDateRange(start, end)
+// GetPersistenceStats reports the state of the database write queue
+func (h *Hub) GetPersistenceStats() persistence.Stats {
+	return h.writer.Stats()
 }
 
 func (h *Hub) GetConnectedClients() int {
@@ -396,7 +601,10 @@ func (h *Hub) startCleanupRoutine() {
 	for range ticker.C {
 		// Clean messages older than 30 days
 		if err := h.messageRepo.DeleteOlderThan(30 * 24 * time.Hour); err != nil {
-			log.Printf("Error cleaning old messages: %v", err)
+			slog.Error("failed to clean old messages", "error", err)
+		}
+		if err := h.reactionRepo.DeleteOlderThan(30 * 24 * time.Hour); err != nil {
+			slog.Error("failed to clean old reactions", "error", err)
 		}
 	}
 }
@@ -0,0 +1,80 @@
+package hub
+
+import (
+	"github.com/gorilla/websocket"
+	"github.com/pollz/websocket-server/internal/metrics"
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+// Reasons recorded when clients are removed from the hub
+const (
+	reasonClientClosed = "client_closed"
+	reasonSlowClient   = "slow_client"
+	reasonAdmin        = "admin"
+	reasonShutdown     = "shutdown"
+)
+
+// closeReason names the reason recorded for a server-side close code
+func closeReason(code int) string {
+	switch code {
+	case models.CloseBanned:
+		return "banned"
+	case models.CloseKicked:
+		return "kicked"
+	case websocket.ClosePolicyViolation:
+		return "policy_violation"
+	}
+	return "server"
+}
+
+// registerMetrics exposes the hub's state as gauges read on every scrape
+func (h *Hub) registerMetrics() {
+	metrics.NewGaugeFunc("pollz_connected_clients", "Clients connected to this instance.", func() float64 {
+		return float64(h.GetConnectedClients())
+	})
+	metrics.NewGaugeFunc("pollz_rooms", "Rooms with state on this instance.", func() float64 {
+		h.mu.RLock()
+		defer h.mu.RUnlock()
+		return float64(len(h.rooms))
+	})
+	metrics.NewGaugeFunc("pollz_send_buffer_max_ratio",
+		"Fill ratio of the fullest client send buffer.", func() float64 {
+			max, _ := h.sendBufferSaturation()
+			return max
+		})
+	metrics.NewGaugeFunc("pollz_send_buffer_saturated_clients",
+		"Clients whose send buffer is more than half full.", func() float64 {
+			_, saturated := h.sendBufferSaturation()
+			return float64(saturated)
+		})
+	metrics.NewGaugeFunc("pollz_lagging_clients",
+		"Clients whose send buffer filled up and that have not caught up yet.", func() float64 {
+			return float64(h.laggingClients())
+		})
+	metrics.NewGaugeFunc("pollz_persist_queue_depth", "Messages waiting to be written to Postgres.", func() float64 {
+		return float64(h.writer.Stats().QueueDepth)
+	})
+}
+
+// sendBufferSaturation returns the fill ratio of the fullest send buffer
+// and the number of buffers more than half full
+func (h *Hub) sendBufferSaturation() (float64, int) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	max := 0.0
+	saturated := 0
+	for client := range h.clients {
+		if cap(client.Send) == 0 {
+			continue
+		}
+		ratio := float64(len(client.Send)) / float64(cap(client.Send))
+		if ratio > max {
+			max = ratio
+		}
+		if ratio > 0.5 {
+			saturated++
+		}
+	}
+	return max, saturated
+}
@@ -0,0 +1,387 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+// moderationChannel carries bans, unbans and kicks to every instance so
+// they can disconnect the affected connections
+const moderationChannel = "pollz:moderation"
+
+// defaultMuteDuration is used when a mute command has no duration
+const defaultMuteDuration = 10 * time.Minute
+
+// moderationEvent is published on moderationChannel
+type moderationEvent struct {
+	Node     string      `json:"node"`
+	Action   string      `json:"action"`
+	UserID   string      `json:"user_id,omitempty"`
+	ClientID string      `json:"client_id,omitempty"`
+	IP       string      `json:"ip,omitempty"`
+	Role     models.Role `json:"role,omitempty"`
+}
+
+// target selects the connections a ban or kick applies to: one connection
+// by client ID, or every connection of a user ID or from an IP address
+type target struct {
+	clientID string
+	userID   string
+	ip       string
+}
+
+func (t target) matches(client *models.Client) bool {
+	if t.clientID != "" {
+		return client.ID == t.clientID
+	}
+	return (t.userID != "" && client.UserID == t.userID) || (t.ip != "" && client.IP == t.ip)
+}
+
+// banList is the in-memory copy of the active bans checked on connect
+type banList struct {
+	mu    sync.RWMutex
+	users map[string]*time.Time
+	ips   map[string]*time.Time
+}
+
+func newBanList() *banList {
+	return &banList{
+		users: make(map[string]*time.Time),
+		ips:   make(map[string]*time.Time),
+	}
+}
+
+func (b *banList) set(bans []models.Ban) {
+	users := make(map[string]*time.Time)
+	ips := make(map[string]*time.Time)
+	for _, ban := range bans {
+		if ban.UserID != "" {
+			users[ban.UserID] = ban.ExpiresAt
+		}
+		if ban.IP != "" {
+			ips[ban.IP] = ban.ExpiresAt
+		}
+	}
+
+	b.mu.Lock()
+	b.users = users
+	b.ips = ips
+	b.mu.Unlock()
+}
+
+// banned reports whether the user ID or IP address is banned at now
+func (b *banList) banned(userID, ip string, now time.Time) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	active := func(expiresAt *time.Time, ok bool) bool {
+		return ok && (expiresAt == nil || now.Before(*expiresAt))
+	}
+	if userID != "" {
+		if expiresAt, ok := b.users[userID]; active(expiresAt, ok) {
+			return true
+		}
+	}
+	if ip != "" {
+		if expiresAt, ok := b.ips[ip]; active(expiresAt, ok) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadBans replaces the in-memory ban list with the active bans
+func (h *Hub) loadBans() {
+	bans, err := h.moderationRepo.ActiveBans()
+	if err != nil {
+		slog.Error("failed to load bans", "error", err)
+		return
+	}
+	h.bans.set(bans)
+}
+
+// handleModeration runs a command frame sent by a moderator and reports
+// the outcome back to them
+func (h *Hub) handleModeration(client *models.Client, message models.Message) {
+	if !client.Role.CanModerate() {
+		client.Log.Warn("moderation command without permission", "user_id", client.UserID)
+		h.notify(client, "You are not allowed to moderate this chat")
+		return
+	}
+	if message.Command == nil {
+		return
+	}
+
+	h.mu.RLock()
+	room := client.Room
+	h.mu.RUnlock()
+
+	result, err := h.Moderate(client.UserID, client.Role, room, *message.Command)
+	if err != nil {
+		client.Log.Info("moderation command failed", "user_id", client.UserID, "error", err)
+		h.notify(client, fmt.Sprintf("Command failed: %v", err))
+		return
+	}
+	h.notify(client, result)
+}
+
+// Moderate applies a moderation command on behalf of moderatorID, whose
+// role must outrank the targets of bans and kicks, records it in the audit
+// log and returns a summary for the moderator. Deletes apply to messages
+// in room.
+func (h *Hub) Moderate(moderatorID string, role models.Role, room string, cmd models.ModCommand) (string, error) {
+	if cmd.Duration < 0 {
+		return "", fmt.Errorf("%w: duration must not be negative", models.ErrInvalidInput)
+	}
+	duration := time.Duration(cmd.Duration) * time.Second
+
+	var result string
+	var err error
+	switch cmd.Action {
+	case models.ModDelete:
+		result, err = h.deleteMessage(moderatorID, room, cmd.MessageID)
+	case models.ModMute:
+		result, err = h.muteByModerator(cmd.UserID, duration)
+	case models.ModUnmute:
+		result, err = h.unmute(cmd.UserID)
+	case models.ModBan:
+		result, err = h.ban(moderatorID, role, cmd, duration)
+	case models.ModUnban:
+		result, err = h.unban(cmd.UserID, cmd.IP)
+	case models.ModKick:
+		result, err = h.kick(role, cmd)
+	default:
+		err = fmt.Errorf("%w: unknown action %q", models.ErrInvalidInput, cmd.Action)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	h.audit(models.ModerationAction{
+		Action:      cmd.Action,
+		ModeratorID: moderatorID,
+		UserID:      cmd.UserID,
+		IP:          cmd.IP,
+		MessageID:   cmd.MessageID,
+		Room:        room,
+		Duration:    cmd.Duration,
+		Reason:      cmd.Reason,
+	})
+
+	return result, nil
+}
+
+// audit records an action in the moderation log
+func (h *Hub) audit(action models.ModerationAction) {
+	if err := h.moderationRepo.LogAction(action); err != nil {
+		slog.Error("failed to record moderation action", "error", err)
+	}
+}
+
+func (h *Hub) deleteMessage(moderatorID, room, id string) (string, error) {
+	if id == "" {
+		return "", fmt.Errorf("%w: missing message_id", models.ErrInvalidInput)
+	}
+	_, found, err := h.findMessage(room, id)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", fmt.Errorf("message %s: %w", id, models.ErrNotFound)
+	}
+
+	if err := h.messageRepo.Tombstone(id, room, moderatorID); err != nil {
+		return "", err
+	}
+	if err := h.messageCache.Remove(room, id); err != nil {
+		slog.Error("failed to remove deleted message from cache", "error", err)
+	}
+	if err := h.scrubReplayBuffer(room, id); err != nil {
+		slog.Error("failed to remove deleted message from replay buffer", "error", err)
+	}
+	if pinned := h.pinnedMessage(room); pinned != nil && pinned.ID == id {
+		h.unpin(room)
+	}
+	h.unpinSuperChat(room, id)
+
+	h.Broadcast(models.Message{
+		Type:      models.MessageDeleted,
+		Room:      room,
+		MessageID: id,
+	})
+	return "Message deleted", nil
+}
+
+func (h *Hub) muteByModerator(userID string, duration time.Duration) (string, error) {
+	if userID == "" {
+		return "", fmt.Errorf("%w: missing user_id", models.ErrInvalidInput)
+	}
+	if duration == 0 {
+		duration = defaultMuteDuration
+	}
+	if err := h.muteUser(userID, duration); err != nil {
+		return "", fmt.Errorf("failed to mute user: %w", err)
+	}
+	return fmt.Sprintf("Muted %s for %s", userID, formatWait(duration)), nil
+}
+
+func (h *Hub) unmute(userID string) (string, error) {
+	if userID == "" {
+		return "", fmt.Errorf("%w: missing user_id", models.ErrInvalidInput)
+	}
+	if err := h.redis.Del(context.Background(), muteKeyPrefix+userID).Err(); err != nil {
+		return "", fmt.Errorf("failed to unmute user: %w", err)
+	}
+	return fmt.Sprintf("Unmuted %s", userID), nil
+}
+
+func (h *Hub) ban(moderatorID string, role models.Role, cmd models.ModCommand, duration time.Duration) (string, error) {
+	if cmd.UserID == "" && cmd.IP == "" {
+		return "", fmt.Errorf("%w: missing user_id or ip", models.ErrInvalidInput)
+	}
+	t := target{userID: cmd.UserID, ip: cmd.IP}
+	if err := h.checkOutranks(t, role); err != nil {
+		return "", err
+	}
+
+	ban := models.Ban{
+		UserID:    cmd.UserID,
+		IP:        cmd.IP,
+		Reason:    cmd.Reason,
+		CreatedBy: moderatorID,
+	}
+	if duration > 0 {
+		expiresAt := time.Now().Add(duration)
+		ban.ExpiresAt = &expiresAt
+	}
+	if _, err := h.moderationRepo.AddBan(ban); err != nil {
+		return "", err
+	}
+
+	h.loadBans()
+	n := h.disconnectMatching(t, role, models.CloseBanned, "banned")
+	h.publishModeration(moderationEvent{Action: models.ModBan, UserID: cmd.UserID, IP: cmd.IP, Role: role})
+
+	length := "permanently"
+	if duration > 0 {
+		length = "for " + formatWait(duration)
+	}
+	return fmt.Sprintf("Banned %s %s, %d connection(s) closed here", banTarget(cmd.UserID, cmd.IP), length, n), nil
+}
+
+func (h *Hub) unban(userID, ip string) (string, error) {
+	if userID == "" && ip == "" {
+		return "", fmt.Errorf("%w: missing user_id or ip", models.ErrInvalidInput)
+	}
+
+	n, err := h.moderationRepo.LiftBans(userID, ip)
+	if err != nil {
+		return "", err
+	}
+	if n == 0 {
+		return "", fmt.Errorf("%s is not banned: %w", banTarget(userID, ip), models.ErrNotFound)
+	}
+
+	h.loadBans()
+	h.publishModeration(moderationEvent{Action: models.ModUnban, UserID: userID, IP: ip})
+	return fmt.Sprintf("Unbanned %s", banTarget(userID, ip)), nil
+}
+
+func (h *Hub) kick(role models.Role, cmd models.ModCommand) (string, error) {
+	if cmd.UserID == "" && cmd.ClientID == "" {
+		return "", fmt.Errorf("%w: missing user_id or client_id", models.ErrInvalidInput)
+	}
+	t := target{clientID: cmd.ClientID, userID: cmd.UserID}
+	if err := h.checkOutranks(t, role); err != nil {
+		return "", err
+	}
+
+	n := h.disconnectMatching(t, role, models.CloseKicked, "kicked by a moderator")
+	h.publishModeration(moderationEvent{Action: models.ModKick, UserID: cmd.UserID, ClientID: cmd.ClientID, Role: role})
+	if cmd.ClientID != "" {
+		return fmt.Sprintf("Kicked connection %s, %d connection(s) closed here", cmd.ClientID, n), nil
+	}
+	return fmt.Sprintf("Kicked %s, %d connection(s) closed here", cmd.UserID, n), nil
+}
+
+// checkOutranks fails if role does not outrank every connection of this
+// instance that t matches
+func (h *Hub) checkOutranks(t target, role models.Role) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for client := range h.clients {
+		if t.matches(client) && !role.Outranks(client.Role) {
+			return fmt.Errorf("%w: %s has the role %s", models.ErrForbidden, client.UserID, client.Role)
+		}
+	}
+	return nil
+}
+
+func banTarget(userID, ip string) string {
+	switch {
+	case userID != "" && ip != "":
+		return userID + " (" + ip + ")"
+	case userID != "":
+		return userID
+	}
+	return ip
+}
+
+// disconnectMatching closes this instance's connections that t matches and
+// role outranks, and returns how many were closed
+func (h *Hub) disconnectMatching(t target, role models.Role, code int, reason string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n := 0
+	for client := range h.clients {
+		if t.matches(client) && role.Outranks(client.Role) {
+			client.CloseCode = code
+			client.CloseReason = reason
+			h.removeClient(client, closeReason(code))
+			n++
+		}
+	}
+	return n
+}
+
+func (h *Hub) publishModeration(event moderationEvent) {
+	event.Node = h.nodeID
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	if err := h.redis.Publish(context.Background(), moderationChannel, data).Err(); err != nil {
+		slog.Error("failed to notify peers of moderation action", "error", err)
+	}
+}
+
+// handleModerationEvent applies a ban, unban or kick made on another
+// instance to this instance's connections
+func (h *Hub) handleModerationEvent(payload string) {
+	var event moderationEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		slog.Error("failed to decode moderation event", "error", err)
+		return
+	}
+	if event.Node == h.nodeID {
+		return
+	}
+
+	switch event.Action {
+	case models.ModBan:
+		h.loadBans()
+		h.disconnectMatching(target{userID: event.UserID, ip: event.IP}, event.Role, models.CloseBanned, "banned")
+	case models.ModUnban:
+		h.loadBans()
+	case models.ModKick:
+		h.disconnectMatching(target{clientID: event.ClientID, userID: event.UserID}, event.Role, models.CloseKicked, "kicked by a moderator")
+	}
+}
@@ -0,0 +1,294 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pollz/websocket-server/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// pollChannel carries poll tallies to every instance
+	pollChannel = "pollz:polls"
+
+	// pollKeyPrefix prefixes the Redis keys holding each poll's latest tally
+	// and, with pollUpdatedSuffix, its updated_at in microseconds
+	pollKeyPrefix     = "pollz:poll:"
+	pollUpdatedSuffix = ":updated_at"
+
+	// pollTallyTTL is how long a poll's latest tally is kept after its
+	// last update
+	pollTallyTTL = 7 * 24 * time.Hour
+
+	// maxPollSubscriptions bounds the polls a single client can follow
+	maxPollSubscriptions = 20
+
+	// defaultPollUpdateInterval is used when no positive interval is set
+	defaultPollUpdateInterval = 500 * time.Millisecond
+)
+
+// storeTally stores and publishes a tally unless the stored one is as new,
+// so tallies arriving out of order cannot replace a newer one. It returns 1
+// if the tally was stored.
+var storeTally = redis.NewScript(`
+local stored = tonumber(redis.call('GET', KEYS[2]) or '-1')
+if tonumber(ARGV[2]) <= stored then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[3])
+redis.call('SET', KEYS[2], ARGV[2], 'PX', ARGV[3])
+redis.call('PUBLISH', ARGV[4], ARGV[1])
+return 1
+`)
+
+// pollSubscriptions tracks which clients follow which polls and the
+// tallies waiting for the next flush
+type pollSubscriptions struct {
+	mu       sync.Mutex
+	interval time.Duration
+	polls    map[string]map[*models.Client]bool
+	clients  map[*models.Client]map[string]bool
+	pending  map[string]models.PollTally
+}
+
+func newPollSubscriptions(interval time.Duration) *pollSubscriptions {
+	if interval <= 0 {
+		interval = defaultPollUpdateInterval
+	}
+	return &pollSubscriptions{
+		interval: interval,
+		polls:    make(map[string]map[*models.Client]bool),
+		clients:  make(map[*models.Client]map[string]bool),
+		pending:  make(map[string]models.PollTally),
+	}
+}
+
+// subscribe adds a client to a poll's subscribers. It returns false if the
+// client already follows too many polls.
+func (s *pollSubscriptions) subscribe(client *models.Client, pollID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	polls := s.clients[client]
+	if polls == nil {
+		polls = make(map[string]bool)
+		s.clients[client] = polls
+	}
+	if !polls[pollID] && len(polls) >= maxPollSubscriptions {
+		return false
+	}
+	polls[pollID] = true
+
+	if s.polls[pollID] == nil {
+		s.polls[pollID] = make(map[*models.Client]bool)
+	}
+	s.polls[pollID][client] = true
+	return true
+}
+
+func (s *pollSubscriptions) unsubscribe(client *models.Client, pollID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(client, pollID)
+}
+
+// forget drops every subscription of a closed connection
+func (s *pollSubscriptions) forget(client *models.Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for pollID := range s.clients[client] {
+		s.remove(client, pollID)
+	}
+}
+
+// remove deletes one subscription. Callers must hold s.mu.
+func (s *pollSubscriptions) remove(client *models.Client, pollID string) {
+	if subscribers, ok := s.polls[pollID]; ok {
+		delete(subscribers, client)
+		if len(subscribers) == 0 {
+			delete(s.polls, pollID)
+			delete(s.pending, pollID)
+		}
+	}
+	if polls, ok := s.clients[client]; ok {
+		delete(polls, pollID)
+		if len(polls) == 0 {
+			delete(s.clients, client)
+		}
+	}
+}
+
+// queue keeps a tally for the next flush, replacing any older one of the
+// same poll. Tallies of polls nobody here follows are dropped.
+func (s *pollSubscriptions) queue(tally models.PollTally) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.polls[tally.PollID]) == 0 {
+		return
+	}
+	if queued, ok := s.pending[tally.PollID]; ok && tally.UpdatedAt.Before(queued.UpdatedAt) {
+		return
+	}
+	s.pending[tally.PollID] = tally
+}
+
+// PublishPollTally stores a poll's latest tally and sends it to every
+// instance. Subscribed clients receive it with the next flush. Tallies
+// older than the stored one are ignored.
+func (h *Hub) PublishPollTally(tally models.PollTally) error {
+	if !models.ValidPollID(tally.PollID) {
+		return fmt.Errorf("%w: invalid poll_id %q", models.ErrInvalidInput, tally.PollID)
+	}
+	if tally.Counts == nil {
+		tally.Counts = map[string]int64{}
+	}
+	if tally.UpdatedAt.IsZero() {
+		tally.UpdatedAt = time.Now()
+	}
+
+	data, err := json.Marshal(tally)
+	if err != nil {
+		return fmt.Errorf("failed to encode poll tally: %w", err)
+	}
+
+	key := pollKeyPrefix + tally.PollID
+	stored, err := storeTally.Run(context.Background(), h.redis,
+		[]string{key, key + pollUpdatedSuffix},
+		data, tally.UpdatedAt.UnixMicro(), pollTallyTTL.Milliseconds(), pollChannel,
+	).Int()
+	if err != nil {
+		return fmt.Errorf("failed to publish poll tally: %w", err)
+	}
+	if stored == 0 {
+		slog.Debug("ignored outdated poll tally", "poll_id", tally.PollID, "updated_at", tally.UpdatedAt)
+	}
+	return nil
+}
+
+// handlePollTally queues a tally received on pollChannel
+func (h *Hub) handlePollTally(payload string) {
+	var tally models.PollTally
+	if err := json.Unmarshal([]byte(payload), &tally); err != nil {
+		slog.Error("failed to decode poll tally", "error", err)
+		return
+	}
+	h.polls.queue(tally)
+}
+
+// startPollFlusher sends the queued tallies to their subscribers, so each
+// poll's clients get at most one snapshot per interval however often the
+// votes change.
+func (h *Hub) startPollFlusher() {
+	ticker := time.NewTicker(h.polls.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.flushPolls()
+		case <-h.done:
+			return
+		}
+	}
+}
+
+func (h *Hub) flushPolls() {
+	tallies := h.polls.takePending()
+	if len(tallies) == 0 {
+		return
+	}
+
+	subscribers := h.polls.subscribersOf(tallies)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for pollID, tally := range tallies {
+		tally := tally
+		message := models.Message{
+			ID:        uuid.New().String(),
+			Type:      models.PollUpdate,
+			PollID:    pollID,
+			Poll:      &tally,
+			CreatedAt: time.Now(),
+		}
+		for _, client := range subscribers[pollID] {
+			h.trySend(client, message)
+		}
+	}
+}
+
+// takePending returns the queued tallies and clears the queue
+func (s *pollSubscriptions) takePending() map[string]models.PollTally {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tallies := s.pending
+	s.pending = make(map[string]models.PollTally)
+	return tallies
+}
+
+// subscribersOf returns the current subscribers of each poll in tallies
+func (s *pollSubscriptions) subscribersOf(tallies map[string]models.PollTally) map[string][]*models.Client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscribers := make(map[string][]*models.Client, len(tallies))
+	for pollID := range tallies {
+		for client := range s.polls[pollID] {
+			subscribers[pollID] = append(subscribers[pollID], client)
+		}
+	}
+	return subscribers
+}
+
+// subscribePoll makes a client follow a poll and sends it the latest tally
+func (h *Hub) subscribePoll(client *models.Client, pollID string) {
+	if !models.ValidPollID(pollID) {
+		client.Log.Info("invalid poll subscription", "poll_id", pollID)
+		return
+	}
+	if !h.polls.subscribe(client, pollID) {
+		h.notify(client, fmt.Sprintf("You can follow at most %d polls", maxPollSubscriptions))
+		return
+	}
+
+	tally, err := h.latestPollTally(pollID)
+	if err != nil {
+		client.Log.Error("failed to get poll tally", "poll_id", pollID, "error", err)
+		return
+	}
+	if tally == nil {
+		return
+	}
+	h.sendDirect(client, models.Message{
+		ID:     uuid.New().String(),
+		Type:   models.PollUpdate,
+		PollID: pollID,
+		Poll:   tally,
+	})
+}
+
+// latestPollTally returns the last tally stored for a poll, or nil if
+// there is none
+func (h *Hub) latestPollTally(pollID string) (*models.PollTally, error) {
+	data, err := h.redis.Get(context.Background(), pollKeyPrefix+pollID).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var tally models.PollTally
+	if err := json.Unmarshal([]byte(data), &tally); err != nil {
+		return nil, fmt.Errorf("failed to decode poll tally: %w", err)
+	}
+	return &tally, nil
+}
@@ -0,0 +1,361 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pollz/websocket-server/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// presenceKeyPrefix prefixes the Redis hashes holding each room's
+	// presence. Every instance writes its own share of the room under its
+	// node ID.
+	presenceKeyPrefix = "pollz:presence:"
+
+	// defaultPresenceInterval is used when no positive interval is set
+	defaultPresenceInterval = 5 * time.Second
+
+	// maxRosterRequests bounds the joins waiting for their roster
+	maxRosterRequests = 1024
+)
+
+// nodePresence is one instance's share of a room's presence
+type nodePresence struct {
+	Conns int                   `json:"conns"`
+	Users []models.PresenceUser `json:"users,omitempty"`
+	At    int64                 `json:"at"`
+}
+
+// roomPresence counts the connections of a room on this instance and the
+// connections of each signed-in user
+type roomPresence struct {
+	conns int
+	users map[string]*presenceUser
+}
+
+type presenceUser struct {
+	name  string
+	conns int
+}
+
+// presence tracks which rooms this instance's clients are in. It is
+// updated from handleRegister, handleJoin and handleUnregister.
+type presence struct {
+	mu       sync.Mutex
+	interval time.Duration
+	rooms    map[string]*roomPresence
+	clients  map[*models.Client]string
+
+	// requests are the clients waiting for the roster of the room they
+	// joined, sent from the presence goroutine so the hub goroutine never
+	// waits on Redis for it
+	requests chan rosterRequest
+
+	// published are the rooms this instance wrote to Redis on the last
+	// refresh and online their last online count sent to clients. They
+	// are only used by the presence goroutine.
+	published map[string]bool
+	online    map[string]int
+}
+
+// rosterRequest asks for a room's roster to be sent to a client
+type rosterRequest struct {
+	client *models.Client
+	room   string
+}
+
+func newPresence(interval time.Duration) *presence {
+	if interval <= 0 {
+		interval = defaultPresenceInterval
+	}
+	return &presence{
+		interval:  interval,
+		rooms:     make(map[string]*roomPresence),
+		clients:   make(map[*models.Client]string),
+		requests:  make(chan rosterRequest, maxRosterRequests),
+		published: make(map[string]bool),
+		online:    make(map[string]int),
+	}
+}
+
+// add counts a client in a room. It reports whether the client's user was
+// not in the room yet.
+func (p *presence) add(client *models.Client, room string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.clients[client] = room
+	r := p.rooms[room]
+	if r == nil {
+		r = &roomPresence{users: make(map[string]*presenceUser)}
+		p.rooms[room] = r
+	}
+	r.conns++
+	if client.UserID == "" {
+		return false
+	}
+	u := r.users[client.UserID]
+	if u == nil {
+		u = &presenceUser{name: client.Username}
+		r.users[client.UserID] = u
+	}
+	u.conns++
+	return u.conns == 1
+}
+
+// remove stops counting a client. It returns the room it was counted in
+// and whether that was its user's last connection to the room.
+func (p *presence) remove(client *models.Client) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	room, ok := p.clients[client]
+	if !ok {
+		return "", false
+	}
+	delete(p.clients, client)
+
+	r := p.rooms[room]
+	r.conns--
+	left := false
+	if u := r.users[client.UserID]; u != nil {
+		u.conns--
+		if u.conns == 0 {
+			delete(r.users, client.UserID)
+			left = true
+		}
+	}
+	if r.conns == 0 {
+		delete(p.rooms, room)
+	}
+	return room, left
+}
+
+// local returns this instance's share of a room's presence
+func (p *presence) local(room string, now time.Time) nodePresence {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	share := nodePresence{At: now.Unix()}
+	r, ok := p.rooms[room]
+	if !ok {
+		return share
+	}
+	share.Conns = r.conns
+	for id, u := range r.users {
+		if len(share.Users) == models.MaxRosterUsers {
+			break
+		}
+		share.Users = append(share.Users, models.PresenceUser{UserID: id, Username: u.name})
+	}
+	return share
+}
+
+// localRooms returns the rooms this instance has clients in
+func (p *presence) localRooms() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	rooms := make([]string, 0, len(p.rooms))
+	for room := range p.rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+// presenceFrame builds the user_joined or user_left frame of a client
+func presenceFrame(t models.MessageType, client *models.Client, room string) models.Message {
+	return models.Message{
+		Type:     t,
+		Room:     room,
+		UserID:   client.UserID,
+		Username: client.Username,
+	}
+}
+
+// joinPresence counts a client that entered a room, announces its user if
+// they were not there yet and asks the presence goroutine to send the
+// client the room's roster. It runs on the hub goroutine.
+func (h *Hub) joinPresence(client *models.Client, room string) {
+	if h.presence.add(client, room) {
+		h.handleBroadcast(presenceFrame(models.UserJoined, client, room))
+	}
+
+	select {
+	case h.presence.requests <- rosterRequest{client: client, room: room}:
+	default:
+		slog.Warn("roster requests full, roster not sent", "room", room)
+	}
+}
+
+// sendRosters sends the waiting clients the roster of the room they
+// joined, reading each room's presence once however many joined it
+func (h *Hub) sendRosters(first rosterRequest) {
+	requests := []rosterRequest{first}
+	for len(requests) < maxRosterRequests {
+		select {
+		case req := <-h.presence.requests:
+			requests = append(requests, req)
+			continue
+		default:
+		}
+		break
+	}
+
+	rosters := make(map[string]models.Message)
+	for _, req := range requests {
+		if _, ok := rosters[req.room]; ok {
+			continue
+		}
+		online, users := h.roster(req.room)
+		rosters[req.room] = models.Message{
+			Type:   models.Presence,
+			Room:   req.room,
+			Online: online,
+			Users:  users,
+		}
+	}
+
+	now := time.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, req := range requests {
+		// Clients that switched rooms since get the next room's roster
+		if req.client.Room != req.room {
+			continue
+		}
+		message := rosters[req.room]
+		message.ID = uuid.New().String()
+		message.CreatedAt = now
+		h.trySend(req.client, message)
+	}
+}
+
+// leavePresence stops counting a client and announces its user left if it
+// was their last connection to the room. It runs on the hub goroutine.
+func (h *Hub) leavePresence(client *models.Client) {
+	if room, left := h.presence.remove(client); left {
+		h.handleBroadcast(presenceFrame(models.UserLeft, client, room))
+	}
+}
+
+// roster returns the number of connections to a room across all instances
+// and up to MaxRosterUsers of its signed-in users
+func (h *Hub) roster(room string) (int, []models.PresenceUser) {
+	now := time.Now()
+	shares := map[string]nodePresence{h.nodeID: h.presence.local(room, now)}
+
+	fields, err := h.redis.HGetAll(context.Background(), presenceKeyPrefix+room).Result()
+	if err != nil {
+		slog.Error("failed to get room presence", "room", room, "error", err)
+	}
+	for node, data := range fields {
+		if node == h.nodeID {
+			continue
+		}
+		var share nodePresence
+		if err := json.Unmarshal([]byte(data), &share); err != nil {
+			continue
+		}
+		if now.Sub(time.Unix(share.At, 0)) <= h.presenceTTL() {
+			shares[node] = share
+		}
+	}
+
+	online := 0
+	seen := make(map[string]bool)
+	users := []models.PresenceUser{}
+	for _, share := range shares {
+		online += share.Conns
+		for _, u := range share.Users {
+			if !seen[u.UserID] && len(users) < models.MaxRosterUsers {
+				seen[u.UserID] = true
+				users = append(users, u)
+			}
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return online, users
+}
+
+// presenceTTL is how long an instance's share of a room's presence counts
+// without being refreshed, so the shares of stopped instances expire
+func (h *Hub) presenceTTL() time.Duration {
+	return 3 * h.presence.interval
+}
+
+// startPresence shares this instance's presence with the others, sends
+// rooms their online count whenever it changes and ends the typing
+// indicators of clients that went quiet
+func (h *Hub) startPresence() {
+	presenceTicker := time.NewTicker(h.presence.interval)
+	defer presenceTicker.Stop()
+	typingTicker := time.NewTicker(typingTimeout / 2)
+	defer typingTicker.Stop()
+
+	for {
+		select {
+		case <-presenceTicker.C:
+			h.refreshPresence()
+		case req := <-h.presence.requests:
+			h.sendRosters(req)
+		case now := <-typingTicker.C:
+			h.expireTyping(now)
+		case <-h.done:
+			return
+		}
+	}
+}
+
+// refreshPresence writes this instance's share of each room's presence to
+// Redis, then sends each local room its online count if it changed
+func (h *Hub) refreshPresence() {
+	ctx := context.Background()
+	now := time.Now()
+	rooms := h.presence.localRooms()
+
+	pipe := h.redis.Pipeline()
+	current := make(map[string]bool, len(rooms))
+	for _, room := range rooms {
+		current[room] = true
+		data, err := json.Marshal(h.presence.local(room, now))
+		if err != nil {
+			continue
+		}
+		key := presenceKeyPrefix + room
+		pipe.HSet(ctx, key, h.nodeID, data)
+		pipe.Expire(ctx, key, h.presenceTTL())
+	}
+	for room := range h.presence.published {
+		if !current[room] {
+			pipe.HDel(ctx, presenceKeyPrefix+room, h.nodeID)
+			delete(h.presence.online, room)
+		}
+	}
+	h.presence.published = current
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		slog.Error("failed to share room presence", "error", err)
+	}
+
+	for _, room := range rooms {
+		online, _ := h.roster(room)
+		if h.presence.online[room] == online {
+			continue
+		}
+		h.presence.online[room] = online
+		h.deliver(models.Message{
+			ID:        uuid.New().String(),
+			Type:      models.Presence,
+			Room:      room,
+			Online:    online,
+			CreatedAt: now,
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package hub
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+// profanityReloadChannel tells every instance to reload the word list after
+// it was edited through one of them. The payload is the editing node's ID.
+const profanityReloadChannel = "pollz:profanity:reload"
+
+func (h *Hub) ListProfanityRules() ([]models.ProfanityRule, error) {
+	return h.profanityRepo.ListRules()
+}
+
+// AddProfanityRule stores a new rule and reloads the word list on all
+// instances.
+func (h *Hub) AddProfanityRule(rule models.ProfanityRule) (models.ProfanityRule, error) {
+	rule.Word = strings.ToLower(strings.TrimSpace(rule.Word))
+	if rule.Word == "" {
+		return rule, fmt.Errorf("%w: word is required", models.ErrInvalidInput)
+	}
+	if rule.Mode == "" {
+		rule.Mode = models.ExactMatch
+	}
+	if !rule.Mode.Valid() {
+		return rule, fmt.Errorf("%w: unknown match mode %q", models.ErrInvalidInput, rule.Mode)
+	}
+	if rule.Room != "" && !models.ValidRoom(rule.Room) {
+		return rule, fmt.Errorf("%w: invalid room %q", models.ErrInvalidInput, rule.Room)
+	}
+
+	rule, err := h.profanityRepo.AddRule(rule)
+	if err != nil {
+		return rule, err
+	}
+	return rule, h.ReloadProfanity()
+}
+
+// DeleteProfanityRule removes a rule and reloads the word list on all
+// instances.
+func (h *Hub) DeleteProfanityRule(id int64) error {
+	if err := h.profanityRepo.DeleteRule(id); err != nil {
+		return err
+	}
+	return h.ReloadProfanity()
+}
+
+// ReloadProfanity reloads the word list from the database and asks peer
+// instances to do the same. Connections are not affected.
+func (h *Hub) ReloadProfanity() error {
+	if err := h.filter.Reload(); err != nil {
+		return err
+	}
+	if err := h.redis.Publish(context.Background(), profanityReloadChannel, h.nodeID).Err(); err != nil {
+		slog.Error("failed to notify peers of word list change", "error", err)
+	}
+	return nil
+}
+
+func (h *Hub) handleProfanityReload(node string) {
+	if node == h.nodeID {
+		return
+	}
+	if err := h.filter.Reload(); err != nil {
+		slog.Error("failed to reload profanity word list", "error", err)
+	}
+}
@@ -0,0 +1,372 @@
+package hub
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pollz/websocket-server/internal/config"
+	"github.com/pollz/websocket-server/internal/metrics"
+	"github.com/pollz/websocket-server/internal/models"
+	"github.com/pollz/websocket-server/internal/ratelimit"
+)
+
+const (
+	// slowModeKey is a Redis hash of room name to slow mode interval in
+	// seconds, shared by all instances
+	slowModeKey = "pollz:slowmode"
+
+	// slowModeChannel tells instances to reload slow mode settings
+	slowModeChannel = "pollz:slowmode:changed"
+
+	// muteKeyPrefix prefixes the Redis keys marking muted users; the key
+	// expires when the mute ends
+	muteKeyPrefix = "pollz:mute:"
+
+	// strikeWindow is how long throttled messages count towards a mute
+	strikeWindow = time.Minute
+
+	// limiterIdleTimeout is how long unused per-user state is kept
+	limiterIdleTimeout = 10 * time.Minute
+)
+
+// offender tracks how often a user was throttled recently
+type offender struct {
+	strikes       int
+	since         time.Time
+	mutedAttempts int
+}
+
+// rateLimiter holds the per-connection and per-user token buckets, slow
+// mode state and strike counts used to escalate persistent abuse.
+type rateLimiter struct {
+	mu sync.Mutex
+
+	connRate        float64
+	connBurst       int
+	userRate        float64
+	userBurst       int
+	muteAfter       int
+	muteDuration    time.Duration
+	disconnectAfter int
+
+	conns     map[*models.Client]*ratelimit.Bucket
+	users     map[string]*ratelimit.Bucket
+	offenders map[string]*offender
+	lastPost  map[string]time.Time
+	slowMode  map[string]time.Duration
+}
+
+func newRateLimiter(cfg *config.Config) *rateLimiter {
+	return &rateLimiter{
+		connRate:        cfg.ConnMessageRate,
+		connBurst:       cfg.ConnMessageBurst,
+		userRate:        cfg.UserMessageRate,
+		userBurst:       cfg.UserMessageBurst,
+		muteAfter:       cfg.MuteAfterStrikes,
+		muteDuration:    cfg.AutoMuteDuration,
+		disconnectAfter: cfg.DisconnectAfterStrikes,
+		conns:           make(map[*models.Client]*ratelimit.Bucket),
+		users:           make(map[string]*ratelimit.Bucket),
+		offenders:       make(map[string]*offender),
+		lastPost:        make(map[string]time.Time),
+		slowMode:        make(map[string]time.Duration),
+	}
+}
+
+// slowModeWait returns how long the user must wait before posting in room
+func (l *rateLimiter) slowModeWait(room, user string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	interval := l.slowMode[room]
+	if interval <= 0 {
+		return 0
+	}
+	last, ok := l.lastPost[room+"\x00"+user]
+	if !ok {
+		return 0
+	}
+	if wait := interval - now.Sub(last); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// allow takes a token from the connection's and the user's bucket, only if
+// both have one, and records the post for slow mode. An empty room records nothing.
+func (l *rateLimiter) allow(client *models.Client, room, user string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	conn, ok := l.conns[client]
+	if !ok {
+		conn = ratelimit.NewBucket(l.connRate, l.connBurst)
+		l.conns[client] = conn
+	}
+	u, ok := l.users[user]
+	if !ok {
+		u = ratelimit.NewBucket(l.userRate, l.userBurst)
+		l.users[user] = u
+	}
+	if !ratelimit.AllowAll(now, conn, u) {
+		return false
+	}
+
+	if room != "" {
+		l.lastPost[room+"\x00"+user] = now
+	}
+	return true
+}
+
+// strike records a throttled message. It reports whether the user reached
+// muteAfter strikes in the current window and must be muted, which starts
+// their count over.
+func (l *rateLimiter) strike(user string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	o, ok := l.offenders[user]
+	if !ok || now.Sub(o.since) > strikeWindow {
+		o = &offender{since: now}
+		l.offenders[user] = o
+	}
+	o.strikes++
+	if o.strikes < l.muteAfter {
+		return false
+	}
+	delete(l.offenders, user)
+	return true
+}
+
+// mutedAttempt records a message sent while muted. It reports whether the
+// user sent disconnectAfter of them since the mute started and must be
+// disconnected, which starts their count over.
+func (l *rateLimiter) mutedAttempt(user string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	o, ok := l.offenders[user]
+	if !ok {
+		o = &offender{since: now}
+		l.offenders[user] = o
+	}
+	o.mutedAttempts++
+	if o.mutedAttempts < l.disconnectAfter {
+		return false
+	}
+	delete(l.offenders, user)
+	return true
+}
+
+// forget drops the state of a closed connection
+func (l *rateLimiter) forget(client *models.Client) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.conns, client)
+}
+
+// prune drops per-user state that no longer affects any decision
+func (l *rateLimiter) prune(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cutoff := now.Add(-limiterIdleTimeout)
+	for user, b := range l.users {
+		if b.Idle(now, cutoff) {
+			delete(l.users, user)
+		}
+	}
+	for key, t := range l.lastPost {
+		if t.Before(cutoff) {
+			delete(l.lastPost, key)
+		}
+	}
+	for user, o := range l.offenders {
+		if o.since.Before(cutoff) {
+			delete(l.offenders, user)
+		}
+	}
+}
+
+// userKey identifies the sender for per-user limits and mutes
+func userKey(client *models.Client) string {
+	if client.UserID != "" {
+		return client.UserID
+	}
+	return "conn:" + client.ID
+}
+
+// allowMessage applies mutes, slow mode and rate limits to a message from
+// client in room. Rejected senders are told why; users who keep hitting the
+// limits are muted and, if they keep sending while muted, disconnected.
+func (h *Hub) allowMessage(client *models.Client, room string) bool {
+	user := userKey(client)
+	now := time.Now()
+
+	if h.muted(client, user, now) {
+		return false
+	}
+
+	if wait := h.limiter.slowModeWait(room, user, now); wait > 0 {
+		metrics.RateLimited.With("slow_mode").Inc()
+		h.notify(client, fmt.Sprintf("Slow mode is on, you can send another message in %s", formatWait(wait)))
+		h.addStrike(client, user, now)
+		return false
+	}
+
+	if !h.limiter.allow(client, room, user, now) {
+		metrics.RateLimited.With("message").Inc()
+		h.notify(client, "You are sending messages too fast")
+		h.addStrike(client, user, now)
+		return false
+	}
+
+	return true
+}
+
+// allowReaction applies mutes and rate limits to a reaction. Reactions share
+// the message buckets but are not subject to slow mode.
+func (h *Hub) allowReaction(client *models.Client) bool {
+	user := userKey(client)
+	now := time.Now()
+
+	if h.muted(client, user, now) {
+		return false
+	}
+
+	if !h.limiter.allow(client, "", user, now) {
+		metrics.RateLimited.With("reaction").Inc()
+		h.notify(client, "You are sending messages too fast")
+		h.addStrike(client, user, now)
+		return false
+	}
+
+	return true
+}
+
+// allowHistory rate limits history requests with the message buckets.
+// Muted users may still read history.
+func (h *Hub) allowHistory(client *models.Client) bool {
+	if !h.limiter.allow(client, "", userKey(client), time.Now()) {
+		metrics.RateLimited.With("history").Inc()
+		h.notify(client, "You are loading messages too fast")
+		return false
+	}
+	return true
+}
+
+// muted reports whether user is muted, telling the client so, and
+// disconnects clients that keep sending while muted
+func (h *Hub) muted(client *models.Client, user string, now time.Time) bool {
+	remaining := h.muteRemaining(user)
+	if remaining <= 0 {
+		return false
+	}
+	metrics.RateLimited.With("muted").Inc()
+	if h.limiter.mutedAttempt(user, now) {
+		h.disconnect(client, websocket.ClosePolicyViolation, "too many messages while muted")
+		return true
+	}
+	h.notify(client, fmt.Sprintf("You are muted for another %s", formatWait(remaining)))
+	return true
+}
+
+func (h *Hub) addStrike(client *models.Client, user string, now time.Time) {
+	if !h.limiter.strike(user, now) {
+		return
+	}
+	if err := h.muteUser(user, h.limiter.muteDuration); err != nil {
+		slog.Error("failed to mute user", "user", user, "error", err)
+		return
+	}
+	h.notify(client, fmt.Sprintf("You have been muted for %s for flooding the chat", formatWait(h.limiter.muteDuration)))
+}
+
+// muteUser mutes a user on all instances for d
+func (h *Hub) muteUser(user string, d time.Duration) error {
+	return h.redis.Set(context.Background(), muteKeyPrefix+user, "1", d).Err()
+}
+
+// muteRemaining returns how long the user stays muted. Redis errors are
+// treated as not muted so an outage does not silence the chat.
+func (h *Hub) muteRemaining(user string) time.Duration {
+	ttl, err := h.redis.PTTL(context.Background(), muteKeyPrefix+user).Result()
+	if err != nil || ttl < 0 {
+		return 0
+	}
+	return ttl
+}
+
+// SetSlowMode limits every user in room to one message per interval on
+// all instances. A zero interval turns slow mode off.
+func (h *Hub) SetSlowMode(room string, interval time.Duration) error {
+	if !models.ValidRoom(room) {
+		return fmt.Errorf("%w: invalid room %q", models.ErrInvalidInput, room)
+	}
+	if interval < 0 {
+		return fmt.Errorf("%w: interval must not be negative", models.ErrInvalidInput)
+	}
+
+	ctx := context.Background()
+	var err error
+	if interval == 0 {
+		err = h.redis.HDel(ctx, slowModeKey, room).Err()
+	} else {
+		err = h.redis.HSet(ctx, slowModeKey, room, int(interval/time.Second)).Err()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store slow mode: %w", err)
+	}
+
+	h.loadSlowModes()
+	if err := h.redis.Publish(ctx, slowModeChannel, h.nodeID).Err(); err != nil {
+		slog.Error("failed to notify peers of slow mode change", "error", err)
+	}
+	return nil
+}
+
+// GetSlowModes returns the slow mode interval in seconds per room
+func (h *Hub) GetSlowModes() map[string]int {
+	h.limiter.mu.Lock()
+	defer h.limiter.mu.Unlock()
+
+	modes := make(map[string]int, len(h.limiter.slowMode))
+	for room, interval := range h.limiter.slowMode {
+		modes[room] = int(interval / time.Second)
+	}
+	return modes
+}
+
+// loadSlowModes replaces the local slow mode settings with the shared ones
+func (h *Hub) loadSlowModes() {
+	values, err := h.redis.HGetAll(context.Background(), slowModeKey).Result()
+	if err != nil {
+		slog.Error("failed to load slow mode settings", "error", err)
+		return
+	}
+
+	modes := make(map[string]time.Duration, len(values))
+	for room, v := range values {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+			modes[room] = time.Duration(seconds) * time.Second
+		}
+	}
+
+	h.limiter.mu.Lock()
+	h.limiter.slowMode = modes
+	h.limiter.mu.Unlock()
+}
+
+// formatWait rounds d to whole seconds, never below one
+func formatWait(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d < time.Second {
+		d = time.Second
+	}
+	return d.String()
+}
@@ -0,0 +1,116 @@
+package hub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pollz/websocket-server/internal/config"
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+func newTestLimiter() *rateLimiter {
+	return newRateLimiter(&config.Config{
+		ConnMessageRate:        0.001,
+		ConnMessageBurst:       2,
+		UserMessageRate:        1,
+		UserMessageBurst:       1,
+		MuteAfterStrikes:       3,
+		AutoMuteDuration:       time.Minute,
+		DisconnectAfterStrikes: 2,
+	})
+}
+
+func TestRateLimiterAllowTakesFromBothOrNeither(t *testing.T) {
+	l := newTestLimiter()
+	client := &models.Client{ID: "c1", UserID: "u1"}
+	// Buckets start at the real time, so keep the test clock ahead of it
+	now := time.Now().Add(time.Second)
+
+	steps := []struct {
+		name  string
+		after time.Duration
+		want  bool
+	}{
+		{name: "first message", want: true},
+		// The user bucket is empty; the connection keeps its last token
+		{name: "user bucket empty", want: false},
+		{name: "still empty", want: false},
+		{name: "user bucket refilled", after: time.Second, want: true},
+		{name: "connection bucket empty", after: 3 * time.Second, want: false},
+	}
+	for _, step := range steps {
+		if got := l.allow(client, "live", "u1", now.Add(step.after)); got != step.want {
+			t.Fatalf("%s: allow = %v, want %v", step.name, got, step.want)
+		}
+	}
+}
+
+func TestRateLimiterStrikes(t *testing.T) {
+	start := time.Now()
+
+	tests := []struct {
+		name string
+		// offsets are the times of the strikes after start
+		offsets []time.Duration
+		want    []bool
+	}{
+		{
+			name:    "muted on the third strike",
+			offsets: []time.Duration{0, time.Second, 2 * time.Second},
+			want:    []bool{false, false, true},
+		},
+		{
+			name:    "count starts over after a mute",
+			offsets: []time.Duration{0, 0, 0, 0, 0, 0},
+			want:    []bool{false, false, true, false, false, true},
+		},
+		{
+			name:    "strikes outside the window expire",
+			offsets: []time.Duration{0, time.Second, strikeWindow + 2*time.Second, strikeWindow + 3*time.Second},
+			want:    []bool{false, false, false, false},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := newTestLimiter()
+			for i, offset := range tc.offsets {
+				if got := l.strike("u1", start.Add(offset)); got != tc.want[i] {
+					t.Fatalf("strike %d = %v, want %v", i+1, got, tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterMutedAttempts(t *testing.T) {
+	l := newTestLimiter()
+	now := time.Now()
+
+	for i, want := range []bool{false, true, false, true} {
+		if got := l.mutedAttempt("u1", now); got != want {
+			t.Fatalf("muted attempt %d = %v, want %v", i+1, got, want)
+		}
+	}
+	if l.mutedAttempt("u2", now) {
+		t.Error("another user's attempts count towards the disconnect")
+	}
+}
+
+func TestRateLimiterSlowMode(t *testing.T) {
+	l := newTestLimiter()
+	l.slowMode["live"] = 10 * time.Second
+	client := &models.Client{ID: "c1", UserID: "u1"}
+	now := time.Now()
+
+	if wait := l.slowModeWait("live", "u1", now); wait != 0 {
+		t.Fatalf("wait before the first post = %s, want 0", wait)
+	}
+	l.allow(client, "live", "u1", now)
+	if wait := l.slowModeWait("live", "u1", now.Add(4*time.Second)); wait != 6*time.Second {
+		t.Errorf("wait = %s, want 6s", wait)
+	}
+	if wait := l.slowModeWait("other", "u1", now); wait != 0 {
+		t.Errorf("wait in a room without slow mode = %s, want 0", wait)
+	}
+}
@@ -0,0 +1,80 @@
+package hub
+
+import (
+	"log/slog"
+
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+// handleReaction adds or removes a client's reaction and broadcasts the new
+// count of the emoji to the room. It runs on the client's read goroutine,
+// so a user's reactions from one connection are applied in order.
+func (h *Hub) handleReaction(client *models.Client, message models.Message) {
+	if client.ReadOnly || client.UserID == "" {
+		h.notify(client, "Sign in to react to messages")
+		return
+	}
+	if message.Action == "" {
+		message.Action = models.ReactionAdd
+	}
+
+	h.mu.RLock()
+	room := client.Room
+	h.mu.RUnlock()
+
+	_, found, err := h.findMessage(room, message.MessageID)
+	if err != nil {
+		client.Log.Error("failed to look up message", "message_id", message.MessageID, "error", err)
+		return
+	}
+	if !found {
+		h.notify(client, "That message no longer exists")
+		return
+	}
+
+	if !h.allowReaction(client) {
+		return
+	}
+
+	var changed bool
+	var count int
+	if message.Action == models.ReactionAdd {
+		changed, count, err = h.reactionRepo.Add(message.MessageID, client.UserID, message.Emoji)
+	} else {
+		changed, count, err = h.reactionRepo.Remove(message.MessageID, client.UserID, message.Emoji)
+	}
+	if err != nil {
+		client.Log.Error("failed to save reaction", "message_id", message.MessageID, "error", err)
+		return
+	}
+	if !changed {
+		return
+	}
+
+	h.Broadcast(models.Message{
+		Type:      models.Reaction,
+		Room:      room,
+		UserID:    client.UserID,
+		MessageID: message.MessageID,
+		Emoji:     message.Emoji,
+		Action:    message.Action,
+		Reactions: models.ReactionCounts{message.Emoji: count},
+	})
+}
+
+// attachReactions fills in the reaction counts of messages
+func (h *Hub) attachReactions(messages []models.Message) {
+	ids := make([]string, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.ID
+	}
+
+	counts, err := h.reactionRepo.Counts(ids)
+	if err != nil {
+		slog.Error("failed to get reaction counts", "error", err)
+		return
+	}
+	for i := range messages {
+		messages[i].Reactions = counts[messages[i].ID]
+	}
+}
@@ -0,0 +1,174 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/pollz/websocket-server/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// relayChannel is the Redis pub/sub channel hub instances use to share
+// accepted messages with each other.
+const relayChannel = "pollz:chat:relay"
+
+// relayDedupTTL is how long a message ID is remembered for de-duplication.
+const relayDedupTTL = 5 * time.Minute
+
+// relayEnvelope wraps a message with the ID of the node that accepted it so
+// an instance can ignore its own publications.
+type relayEnvelope struct {
+	Node    string         `json:"node"`
+	Message models.Message `json:"message"`
+}
+
+// startRelay queues accepted messages for publication and delivers messages
+// published by peer instances to local clients.
+func (h *Hub) startRelay() {
+	ctx := context.Background()
+	pubsub := h.redis.Subscribe(ctx, relayChannel, profanityReloadChannel, slowModeChannel, moderationChannel, pollChannel, stickerReloadChannel)
+	defer pubsub.Close()
+
+	messages := pubsub.Channel()
+	for {
+		var msg *redis.Message
+		select {
+		case m, ok := <-messages:
+			if !ok {
+				return
+			}
+			msg = m
+		case <-h.done:
+			return
+		}
+
+		switch msg.Channel {
+		case profanityReloadChannel:
+			h.handleProfanityReload(msg.Payload)
+			continue
+		case slowModeChannel:
+			if msg.Payload != h.nodeID {
+				h.loadSlowModes()
+			}
+			continue
+		case moderationChannel:
+			h.handleModerationEvent(msg.Payload)
+			continue
+		case pollChannel:
+			h.handlePollTally(msg.Payload)
+			continue
+		case stickerReloadChannel:
+			h.handleStickerReload(msg.Payload)
+			continue
+		}
+
+		var env relayEnvelope
+		if err := json.Unmarshal([]byte(msg.Payload), &env); err != nil {
+			slog.Error("failed to decode relayed message", "error", err)
+			continue
+		}
+		if env.Node == h.nodeID {
+			continue
+		}
+		select {
+		case h.remote <- env.Message:
+		case <-h.done:
+			return
+		}
+	}
+}
+
+// publish hands a locally accepted message to the publisher goroutine. It
+// never blocks the hub; if the outbox is full the message only reaches this
+// instance's clients.
+func (h *Hub) publish(message models.Message) {
+	select {
+	case h.outbox <- message:
+	default:
+		slog.Warn("relay outbox full, message not published to peers", "message_id", message.ID)
+	}
+}
+
+// runPublisher publishes queued messages in order until the hub stops,
+// then flushes whatever is still queued.
+func (h *Hub) runPublisher() {
+	defer h.pending.Done()
+
+	for {
+		select {
+		case message := <-h.outbox:
+			h.publishNow(message)
+		case <-h.done:
+			for {
+				select {
+				case message := <-h.outbox:
+					h.publishNow(message)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (h *Hub) publishNow(message models.Message) {
+	ctx := context.Background()
+	data, err := h.relayPayload(message)
+	if err != nil {
+		slog.Error("failed to encode relayed message", "error", err)
+		return
+	}
+	if err := h.redis.Publish(ctx, relayChannel, data).Err(); err != nil {
+		slog.Error("failed to publish message", "message_id", message.ID, "error", err)
+	}
+}
+
+// relayPayload encodes a message for the relay channel
+func (h *Hub) relayPayload(message models.Message) ([]byte, error) {
+	return json.Marshal(relayEnvelope{Node: h.nodeID, Message: message})
+}
+
+// handleRemote delivers a message accepted by a peer instance to local
+// clients. Peers have already censored and saved it.
+func (h *Hub) handleRemote(message models.Message) {
+	if !h.seen.add(message.ID, time.Now()) {
+		return
+	}
+	h.deliver(message)
+}
+
+// dedupSet remembers recently seen message IDs. It is only used from the hub
+// goroutine.
+type dedupSet struct {
+	ttl       time.Duration
+	seen      map[string]time.Time
+	lastSweep time.Time
+}
+
+func newDedupSet(ttl time.Duration) *dedupSet {
+	return &dedupSet{
+		ttl:       ttl,
+		seen:      make(map[string]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+// add records id and reports whether it was not already present.
+func (d *dedupSet) add(id string, now time.Time) bool {
+	if now.Sub(d.lastSweep) >= d.ttl {
+		for k, t := range d.seen {
+			if now.Sub(t) >= d.ttl {
+				delete(d.seen, k)
+			}
+		}
+		d.lastSweep = now
+	}
+
+	if _, ok := d.seen[id]; ok {
+		return false
+	}
+	d.seen[id] = now
+	return true
+}
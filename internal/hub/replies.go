@@ -0,0 +1,61 @@
+package hub
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+// attachReplyPreview checks that the message a client replies to exists in
+// its room and quotes it in the reply. It tells the client and returns
+// false if the reply cannot be sent.
+func (h *Hub) attachReplyPreview(client *models.Client, message *models.Message) bool {
+	parent, found, err := h.findMessage(message.Room, message.ReplyTo)
+	if err != nil {
+		client.Log.Error("failed to look up replied message", "message_id", message.ReplyTo, "error", err)
+		h.notify(client, "Your reply could not be sent, please try again")
+		return false
+	}
+	if !found {
+		h.notify(client, "The message you replied to no longer exists")
+		return false
+	}
+
+	message.ReplyPreview = models.NewReplyPreview(parent)
+	return true
+}
+
+// findMessage looks up a message posted in room, checking the recent
+// message cache before the database
+func (h *Hub) findMessage(room, id string) (models.Message, bool, error) {
+	if msg, ok, err := h.messageCache.Find(room, id); err == nil && ok {
+		return msg, true, nil
+	}
+
+	msg, err := h.messageRepo.GetByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return msg, false, nil
+	}
+	if err != nil {
+		return msg, false, err
+	}
+	return msg, msg.Room == room, nil
+}
+
+// GetThread returns a message and a page of the replies to it. It returns
+// an error wrapping sql.ErrNoRows if the message does not exist.
+func (h *Hub) GetThread(id string, limit, offset int) (models.Thread, error) {
+	parent, err := h.messageRepo.GetByID(id)
+	if err != nil {
+		return models.Thread{}, err
+	}
+
+	replies, total, err := h.messageRepo.GetReplies(id, limit, offset)
+	if err != nil {
+		return models.Thread{}, fmt.Errorf("failed to get thread: %w", err)
+	}
+
+	return models.Thread{Parent: parent, Replies: replies, Total: total}, nil
+}
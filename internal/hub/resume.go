@@ -0,0 +1,260 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/pollz/websocket-server/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// seqKeyPrefix prefixes the Redis counters numbering each room's
+	// broadcasts
+	seqKeyPrefix = "pollz:seq:"
+
+	// replayKeyPrefix prefixes the Redis sorted sets holding each room's
+	// latest broadcasts, scored by sequence number
+	replayKeyPrefix = "pollz:replay:"
+
+	// maxReplayMessages bounds the broadcasts replayed to a reconnecting
+	// client so they fit in its send buffer; clients that missed more get
+	// a fresh snapshot
+	maxReplayMessages = 100
+
+	// maxSequenceBatch bounds the broadcasts numbered in one round trip
+	maxSequenceBatch = 128
+)
+
+// runSequencer numbers the broadcasts accepted by the hub, buffers them
+// for replay and fans them out, in the order they were accepted. It runs
+// off the hub goroutine so a slow Redis does not hold up registrations
+// and room changes, and numbers every broadcast waiting at once so a burst
+// costs two round trips. When the hub stops it finishes what is queued.
+func (h *Hub) runSequencer() {
+	defer h.pending.Done()
+
+	batch := make([]models.Message, 0, maxSequenceBatch)
+	for {
+		select {
+		case message := <-h.unsequenced:
+			batch = append(batch[:0], message)
+		case <-h.done:
+			for {
+				batch = h.takeUnsequenced(batch[:0])
+				if len(batch) == 0 {
+					return
+				}
+				h.sequenceBatch(batch)
+			}
+		}
+		h.sequenceBatch(h.takeUnsequenced(batch))
+	}
+}
+
+// takeUnsequenced appends the broadcasts already waiting to batch, up to
+// maxSequenceBatch
+func (h *Hub) takeUnsequenced(batch []models.Message) []models.Message {
+	for len(batch) < maxSequenceBatch {
+		select {
+		case message := <-h.unsequenced:
+			batch = append(batch, message)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// sequenceBatch numbers each broadcast with its room's next sequence
+// number, adds it to the room's replay buffer, publishes it to the other
+// instances and delivers it to local clients. Chat messages are also
+// pushed to the recent messages cache and queued for the database, in
+// order. The counters are shared by
+// all instances. If Redis is unavailable the messages are sent unnumbered.
+func (h *Hub) sequenceBatch(batch []models.Message) {
+	ctx := context.Background()
+
+	pipe := h.redis.Pipeline()
+	seqs := make([]*redis.IntCmd, len(batch))
+	for i, message := range batch {
+		seqs[i] = pipe.Incr(ctx, seqKeyPrefix+message.Room)
+	}
+	pipe.Exec(ctx)
+
+	pipe = h.redis.Pipeline()
+	for i := range batch {
+		message := &batch[i]
+		if seq, err := seqs[i].Result(); err == nil {
+			message.Seq = seq
+			key := replayKeyPrefix + message.Room
+			pipe.ZAdd(ctx, key, redis.Z{Score: float64(seq), Member: mustMarshalString(message)})
+			pipe.ZRemRangeByRank(ctx, key, 0, int64(-h.replayBufferSize-1))
+			pipe.Expire(ctx, key, h.replayTTL)
+		} else {
+			slog.Error("failed to number message", "message_id", message.ID, "error", err)
+		}
+
+		if !message.Type.IsEvent() {
+			if err := h.messageCache.PushPipe(ctx, pipe, *message); err != nil {
+				slog.Error("failed to save message to cache", "message_id", message.ID, "error", err)
+			}
+		}
+		if data, err := h.relayPayload(*message); err == nil {
+			pipe.Publish(ctx, relayChannel, data)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		slog.Error("failed to buffer and publish messages", "count", len(batch), "error", err)
+	}
+
+	for _, message := range batch {
+		if !message.Type.IsEvent() {
+			h.writer.Enqueue(message)
+		}
+		h.deliver(message)
+	}
+}
+
+// replaceReplayed swaps a member of a replay buffer for another with the
+// same score
+var replaceReplayed = redis.NewScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 1 then
+	redis.call('ZADD', KEYS[1], ARGV[3], ARGV[2])
+end
+return 0
+`)
+
+// scrubReplayBuffer drops the content of a deleted message and the
+// previews of it in replies from the room's replay buffer, so resuming
+// clients do not receive it. The deletion itself reaches them as the
+// message_deleted frame that follows.
+func (h *Hub) scrubReplayBuffer(room, id string) error {
+	ctx := context.Background()
+	key := replayKeyPrefix + room
+
+	members, err := h.redis.ZRangeWithScores(ctx, key, 0, -1).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read replay buffer: %w", err)
+	}
+	for _, member := range members {
+		raw, ok := member.Member.(string)
+		if !ok {
+			continue
+		}
+		var msg models.Message
+		if err := json.Unmarshal([]byte(raw), &msg); err != nil {
+			continue
+		}
+		switch {
+		case msg.ID == id && msg.Content != "":
+			msg.Content = ""
+			msg.ReplyPreview = nil
+		case msg.ReplyTo == id && msg.ReplyPreview != nil:
+			msg.ReplyPreview = nil
+		default:
+			continue
+		}
+		err := replaceReplayed.Run(ctx, h.redis, []string{key}, raw, mustMarshalString(msg), member.Score).Err()
+		if err != nil {
+			return fmt.Errorf("failed to update replay buffer: %w", err)
+		}
+	}
+	return nil
+}
+
+// currentSeq returns the room's latest sequence number
+func (h *Hub) currentSeq(room string) (int64, error) {
+	seq, err := h.redis.Get(context.Background(), seqKeyPrefix+room).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return seq, err
+}
+
+// resume sends a reconnecting client the broadcasts of its room after
+// lastSeq, followed by a resumed frame. It reports false without sending
+// anything if they cannot all be replayed.
+func (h *Hub) resume(client *models.Client, room string, lastSeq int64) bool {
+	current, err := h.currentSeq(room)
+	if err != nil {
+		slog.Error("failed to get sequence number", "room", room, "error", err)
+		return false
+	}
+	if lastSeq > current || current-lastSeq > maxReplayMessages {
+		return false
+	}
+
+	missed := h.replayFromBuffer(room, lastSeq, current)
+	if missed == nil {
+		missed = h.replayFromDatabase(room, lastSeq, current)
+	}
+	if missed == nil {
+		return false
+	}
+
+	h.sendInRoom(client, room, append(missed, models.Message{
+		Type: models.Resumed,
+		Room: room,
+		Seq:  current,
+	})...)
+	return true
+}
+
+// replayFromBuffer returns the broadcasts in (after, upto] from the room's
+// replay buffer, or nil if the buffer no longer holds all of them
+func (h *Hub) replayFromBuffer(room string, after, upto int64) []models.Message {
+	members, err := h.redis.ZRangeByScore(context.Background(), replayKeyPrefix+room, &redis.ZRangeBy{
+		Min: "(" + strconv.FormatInt(after, 10),
+		Max: strconv.FormatInt(upto, 10),
+	}).Result()
+	if err != nil {
+		slog.Error("failed to read replay buffer", "room", room, "error", err)
+		return nil
+	}
+
+	messages := make([]models.Message, 0, len(members))
+	for _, member := range members {
+		var msg models.Message
+		if err := json.Unmarshal([]byte(member), &msg); err != nil {
+			return nil
+		}
+		messages = append(messages, msg)
+	}
+	if !contiguous(messages, after, upto) {
+		return nil
+	}
+	return messages
+}
+
+// replayFromDatabase returns the stored messages in (after, upto]. Events
+// are not stored, so this only succeeds if none were broadcast in between.
+func (h *Hub) replayFromDatabase(room string, after, upto int64) []models.Message {
+	messages, err := h.messageRepo.GetBySeq(room, after, upto)
+	if err != nil {
+		slog.Error("failed to read messages for replay", "room", room, "error", err)
+		return nil
+	}
+	if !contiguous(messages, after, upto) {
+		return nil
+	}
+	return messages
+}
+
+// contiguous reports whether messages are exactly the sequence numbers in
+// (after, upto], in order
+func contiguous(messages []models.Message, after, upto int64) bool {
+	if int64(len(messages)) != upto-after {
+		return false
+	}
+	for i, msg := range messages {
+		if msg.Seq != after+int64(i)+1 {
+			return false
+		}
+	}
+	return true
+}
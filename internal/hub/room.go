@@ -0,0 +1,71 @@
+package hub
+
+import (
+	"time"
+
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+// Room is a named chat channel. Broadcasts only reach the clients of the
+// room the message was sent to.
+type Room struct {
+	name       string
+	clients    map[*models.Client]bool
+	lastActive time.Time
+}
+
+func newRoom(name string) *Room {
+	return &Room{
+		name:       name,
+		clients:    make(map[*models.Client]bool),
+		lastActive: time.Now(),
+	}
+}
+
+// idle reports whether the room has had no members and no traffic for at
+// least timeout.
+func (r *Room) idle(now time.Time, timeout time.Duration) bool {
+	return len(r.clients) == 0 && now.Sub(r.lastActive) >= timeout
+}
+
+// room returns the named room, creating it on first use. Callers must hold
+// h.mu for writing.
+func (h *Hub) room(name string) *Room {
+	if r, ok := h.rooms[name]; ok {
+		return r
+	}
+	r := newRoom(name)
+	h.rooms[name] = r
+	return r
+}
+
+// startRoomJanitor periodically tears down rooms that have been idle for
+// longer than the configured timeout.
+func (h *Hub) startRoomJanitor() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		h.mu.Lock()
+		for name, r := range h.rooms {
+			if r.idle(now, h.roomIdleTimeout) {
+				delete(h.rooms, name)
+			}
+		}
+		h.mu.Unlock()
+
+		h.limiter.prune(now)
+	}
+}
+
+// GetRoomCounts returns the number of connected clients per active room.
+func (h *Hub) GetRoomCounts() map[string]int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	counts := make(map[string]int, len(h.rooms))
+	for name, r := range h.rooms {
+		counts[name] = len(r.clients)
+	}
+	return counts
+}
@@ -0,0 +1,81 @@
+package hub
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+// Clients are asked to wait a random delay in this range before
+// reconnecting, so a restart does not turn into a reconnect stampede.
+const (
+	minReconnectDelay = 2 * time.Second
+	maxReconnectDelay = 10 * time.Second
+)
+
+// Shutdown notifies every client that the server is going away, closes
+// their connections with CloseGoingAway, stops Run and waits for pending
+// cache and database writes until ctx expires. Messages that cannot be
+// written to the database in time are left in the journal.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	stopped := make(chan struct{})
+	select {
+	case h.stop <- stopped:
+	case <-h.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	<-stopped
+
+	flushed := make(chan struct{})
+	go func() {
+		h.pending.Wait()
+		close(flushed)
+	}()
+
+	select {
+	case <-flushed:
+	case <-ctx.Done():
+		return fmt.Errorf("pending writes not flushed: %w", ctx.Err())
+	}
+
+	return h.writer.Close(ctx)
+}
+
+// handleShutdown runs on the hub goroutine. It accepts the messages that
+// are already queued, then disconnects every client.
+func (h *Hub) handleShutdown() {
+	for len(h.broadcast) > 0 {
+		h.handleBroadcast(<-h.broadcast)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for client := range h.clients {
+		delay := minReconnectDelay + time.Duration(rand.Int63n(int64(maxReconnectDelay-minReconnectDelay)))
+		select {
+		case client.Send <- models.Message{
+			ID:         uuid.New().String(),
+			Type:       models.SystemMessage,
+			Room:       client.Room,
+			Content:    "Server is restarting, reconnecting shortly",
+			RetryAfter: int(delay / time.Second),
+			CreatedAt:  time.Now(),
+		}:
+		default:
+		}
+		client.CloseCode = websocket.CloseGoingAway
+		client.CloseReason = fmt.Sprintf("server restarting, reconnect in %ds", int(delay/time.Second))
+		h.removeClient(client, reasonShutdown)
+	}
+
+	slog.Info("hub stopped, all clients disconnected")
+}
@@ -0,0 +1,176 @@
+package hub
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/pollz/websocket-server/internal/models"
+	"github.com/pollz/websocket-server/internal/repository"
+)
+
+// stickerReloadChannel tells every instance to reload the sticker catalog
+// after it was edited through the admin API
+const stickerReloadChannel = "pollz:stickers:reload"
+
+// stickerCatalog keeps the sticker packs in memory so sticker messages can
+// be checked without a query
+type stickerCatalog struct {
+	repo *repository.StickerRepository
+
+	mu    sync.RWMutex
+	packs []models.StickerPack
+	// packOf maps each sticker ID to the index of its pack
+	packOf map[string]int
+}
+
+func newStickerCatalog(repo *repository.StickerRepository) *stickerCatalog {
+	return &stickerCatalog{repo: repo, packOf: make(map[string]int)}
+}
+
+func (c *stickerCatalog) reload() error {
+	packs, err := c.repo.ListPacks()
+	if err != nil {
+		return err
+	}
+
+	packOf := make(map[string]int)
+	for i, pack := range packs {
+		for _, sticker := range pack.Stickers {
+			packOf[sticker.ID] = i
+		}
+	}
+
+	c.mu.Lock()
+	c.packs = packs
+	c.packOf = packOf
+	c.mu.Unlock()
+	return nil
+}
+
+// available reports whether the sticker belongs to an enabled pack that
+// can be used in the room
+func (c *stickerCatalog) available(id, room string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	i, ok := c.packOf[id]
+	if !ok {
+		return false
+	}
+	pack := c.packs[i]
+	return pack.Enabled && (pack.Room == "" || pack.Room == room)
+}
+
+// list returns the enabled packs that can be used in the room. An empty
+// room returns the enabled packs of all rooms.
+func (c *stickerCatalog) list(room string) []models.StickerPack {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	packs := []models.StickerPack{}
+	for _, pack := range c.packs {
+		if pack.Enabled && (room == "" || pack.Room == "" || pack.Room == room) {
+			packs = append(packs, pack)
+		}
+	}
+	return packs
+}
+
+// owner returns the ID of the pack the sticker belongs to
+func (c *stickerCatalog) owner(id string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	i, ok := c.packOf[id]
+	if !ok {
+		return "", false
+	}
+	return c.packs[i].ID, true
+}
+
+// GetStickerPacks returns the packs clients may use in the room
+func (h *Hub) GetStickerPacks(room string) []models.StickerPack {
+	return h.stickers.list(room)
+}
+
+// ListStickerPacks returns every pack, including disabled ones
+func (h *Hub) ListStickerPacks() []models.StickerPack {
+	h.stickers.mu.RLock()
+	defer h.stickers.mu.RUnlock()
+	return append([]models.StickerPack{}, h.stickers.packs...)
+}
+
+// SaveStickerPack creates or replaces a pack and reloads the catalog on
+// all instances
+func (h *Hub) SaveStickerPack(pack models.StickerPack) (models.StickerPack, error) {
+	pack.Name = strings.TrimSpace(pack.Name)
+	if !models.ValidStickerID(pack.ID) {
+		return pack, fmt.Errorf("%w: invalid pack id %q", models.ErrInvalidInput, pack.ID)
+	}
+	if pack.Name == "" {
+		return pack, fmt.Errorf("%w: empty pack name", models.ErrInvalidInput)
+	}
+	if pack.Room != "" && !models.ValidRoom(pack.Room) {
+		return pack, fmt.Errorf("%w: invalid room %q", models.ErrInvalidInput, pack.Room)
+	}
+
+	seen := make(map[string]bool, len(pack.Stickers))
+	for _, sticker := range pack.Stickers {
+		if !models.ValidStickerID(sticker.ID) || seen[sticker.ID] {
+			return pack, fmt.Errorf("%w: invalid or duplicate sticker id %q", models.ErrInvalidInput, sticker.ID)
+		}
+		if owner, ok := h.stickers.owner(sticker.ID); ok && owner != pack.ID {
+			return pack, fmt.Errorf("%w: sticker %s belongs to pack %s", models.ErrInvalidInput, sticker.ID, owner)
+		}
+		if sticker.ImageURL == "" {
+			return pack, fmt.Errorf("%w: sticker %s has no image_url", models.ErrInvalidInput, sticker.ID)
+		}
+		seen[sticker.ID] = true
+	}
+
+	pack, err := h.stickerRepo.SavePack(pack)
+	if err != nil {
+		return pack, err
+	}
+	return pack, h.ReloadStickers()
+}
+
+// SetStickerPackEnabled enables or disables a pack on all instances
+func (h *Hub) SetStickerPackEnabled(id string, enabled bool) error {
+	if err := h.stickerRepo.SetPackEnabled(id, enabled); err != nil {
+		return err
+	}
+	return h.ReloadStickers()
+}
+
+// DeleteStickerPack removes a pack and its stickers on all instances
+func (h *Hub) DeleteStickerPack(id string) error {
+	if err := h.stickerRepo.DeletePack(id); err != nil {
+		return err
+	}
+	return h.ReloadStickers()
+}
+
+// ReloadStickers reloads the sticker catalog from the database and asks
+// peer instances to do the same
+func (h *Hub) ReloadStickers() error {
+	if err := h.stickers.reload(); err != nil {
+		return err
+	}
+	if err := h.redis.Publish(context.Background(), stickerReloadChannel, h.nodeID).Err(); err != nil {
+		slog.Error("failed to notify peers of sticker catalog change", "error", err)
+	}
+	return nil
+}
+
+func (h *Hub) handleStickerReload(node string) {
+	if node == h.nodeID {
+		return
+	}
+	if err := h.stickers.reload(); err != nil {
+		slog.Error("failed to reload sticker catalog", "error", err)
+	}
+}
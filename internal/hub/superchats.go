@@ -0,0 +1,133 @@
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pollz/websocket-server/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// superChatKeyPrefix prefixes the Redis sorted sets holding each room's
+	// pinned superchats, scored by the Unix millisecond their pin ends
+	superChatKeyPrefix = "pollz:superchats:"
+
+	// superChatSetTTL outlasts the longest pin, so a room's set expires
+	// once its last pin has ended
+	superChatSetTTL = time.Hour
+)
+
+func validateSuperChat(event models.Event) error {
+	if event.PaymentID == "" || event.UserID == "" || event.SuperChat == nil {
+		return fmt.Errorf("%w: superchat events need payment_id, user_id and superchat", models.ErrInvalidInput)
+	}
+	if _, ok := models.SuperChatTierFor(event.SuperChat.Amount, event.SuperChat.Currency); !ok {
+		return fmt.Errorf("%w: %d %s is below the minimum superchat or an unsupported currency",
+			models.ErrInvalidInput, event.SuperChat.Amount, event.SuperChat.Currency)
+	}
+	return nil
+}
+
+// publishSuperChat records a paid superchat, pins it in its room for its
+// tier's duration and broadcasts it. A payment that already paid for a
+// superchat is not published again.
+func (h *Hub) publishSuperChat(message models.Message, event models.Event) (string, bool, error) {
+	tier, _ := models.SuperChatTierFor(event.SuperChat.Amount, event.SuperChat.Currency)
+	message.Type = models.SuperChat
+	message.SuperChat = &models.SuperChatInfo{
+		Amount:      event.SuperChat.Amount,
+		Currency:    strings.ToUpper(event.SuperChat.Currency),
+		Tier:        tier.Level,
+		PinnedUntil: message.CreatedAt.Add(tier.PinDuration),
+	}
+	message.Content = h.removeBad(message.Room, message.Content)
+
+	messageID, created, err := h.superChatRepo.Create(message, event.PaymentID)
+	if err != nil {
+		return "", false, err
+	}
+	if !created {
+		return messageID, true, nil
+	}
+
+	h.pinSuperChat(message)
+	h.Broadcast(message)
+	return message.ID, false, nil
+}
+
+func (h *Hub) pinSuperChat(message models.Message) {
+	ctx := context.Background()
+	key := superChatKeyPrefix + message.Room
+	until := message.SuperChat.PinnedUntil
+
+	err := h.redis.ZAdd(ctx, key, redis.Z{
+		Score:  float64(until.UnixMilli()),
+		Member: mustMarshalString(message),
+	}).Err()
+	if err != nil {
+		slog.Error("failed to pin superchat", "message_id", message.ID, "error", err)
+		return
+	}
+	h.redis.Expire(ctx, key, superChatSetTTL)
+}
+
+// pinnedSuperChats returns the room's superchats whose pin has not ended,
+// oldest first
+func (h *Hub) pinnedSuperChats(room string) []models.Message {
+	ctx := context.Background()
+	key := superChatKeyPrefix + room
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+
+	h.redis.ZRemRangeByScore(ctx, key, "-inf", "("+now)
+	members, err := h.redis.ZRangeByScore(ctx, key, &redis.ZRangeBy{Min: now, Max: "+inf"}).Result()
+	if err != nil {
+		slog.Error("failed to get pinned superchats", "room", room, "error", err)
+		return nil
+	}
+
+	messages := make([]models.Message, 0, len(members))
+	for _, member := range members {
+		var msg models.Message
+		if err := json.Unmarshal([]byte(member), &msg); err != nil {
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+// unpinSuperChat removes a deleted superchat from the room's pins
+func (h *Hub) unpinSuperChat(room, id string) {
+	ctx := context.Background()
+	key := superChatKeyPrefix + room
+
+	members, err := h.redis.ZRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		slog.Error("failed to get pinned superchats", "room", room, "error", err)
+		return
+	}
+	for _, member := range members {
+		var msg models.Message
+		if err := json.Unmarshal([]byte(member), &msg); err == nil && msg.ID == id {
+			h.redis.ZRem(ctx, key, member)
+		}
+	}
+}
+
+// GetSuperChatTotals sums superchats per "user" (optionally within a room)
+// or per "room"
+func (h *Hub) GetSuperChatTotals(group, room string, limit int) ([]models.SuperChatTotal, error) {
+	switch group {
+	case "user":
+		return h.superChatRepo.TotalsByUser(room, limit)
+	case "room":
+		return h.superChatRepo.TotalsByRoom(limit)
+	}
+	return nil, fmt.Errorf("%w: group must be user or room", models.ErrInvalidInput)
+}
@@ -0,0 +1,168 @@
+package hub
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+const (
+	// typingTimeout ends a typing indicator whose client sent no
+	// typing_start for that long
+	typingTimeout = 6 * time.Second
+
+	// typingDebounce is the shortest time between two typing_start frames
+	// relayed for the same client
+	typingDebounce = time.Second
+)
+
+// typist is a client that sent typing_start
+type typist struct {
+	room      string
+	typing    bool
+	started   time.Time
+	expiresAt time.Time
+}
+
+// typingState tracks the clients currently typing
+type typingState struct {
+	mu      sync.Mutex
+	clients map[*models.Client]*typist
+}
+
+func newTypingState() *typingState {
+	return &typingState{clients: make(map[*models.Client]*typist)}
+}
+
+// start records that a client is typing in a room. It reports whether the
+// start should be relayed: repeated starts only extend the indicator, and
+// a client that stopped is not relayed again within typingDebounce.
+func (s *typingState) start(client *models.Client, room string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.clients[client]
+	if t == nil {
+		t = &typist{}
+		s.clients[client] = t
+	}
+	if t.typing && t.room == room {
+		t.expiresAt = now.Add(typingTimeout)
+		return false
+	}
+	if !t.typing && now.Sub(t.started) < typingDebounce {
+		return false
+	}
+	t.room = room
+	t.typing = true
+	t.started = now
+	t.expiresAt = now.Add(typingTimeout)
+	return true
+}
+
+// stop records that a client stopped typing. It returns the room it was
+// typing in and whether it was.
+func (s *typingState) stop(client *models.Client) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.clients[client]
+	if t == nil || !t.typing {
+		return "", false
+	}
+	t.typing = false
+	return t.room, true
+}
+
+// forget drops a closed connection, returning the room it was typing in
+// and whether it was
+func (s *typingState) forget(client *models.Client) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.clients[client]
+	delete(s.clients, client)
+	if t == nil || !t.typing {
+		return "", false
+	}
+	return t.room, true
+}
+
+// expired ends the indicators that timed out and returns their clients
+// with the room they were typing in
+func (s *typingState) expired(now time.Time) map[*models.Client]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ended map[*models.Client]string
+	for client, t := range s.clients {
+		if !t.typing {
+			if now.Sub(t.started) >= typingDebounce {
+				delete(s.clients, client)
+			}
+			continue
+		}
+		if now.After(t.expiresAt) {
+			if ended == nil {
+				ended = make(map[*models.Client]string)
+			}
+			ended[client] = t.room
+			t.typing = false
+		}
+	}
+	return ended
+}
+
+// typingFrame builds the typing_start or typing_stop frame of a client
+func typingFrame(t models.MessageType, client *models.Client, room string) models.Message {
+	return models.Message{
+		Type:     t,
+		Room:     room,
+		UserID:   client.UserID,
+		Username: client.Username,
+	}
+}
+
+// handleTyping relays typing_start and typing_stop frames to the client's
+// room. Frames from clients that cannot send messages are ignored rather
+// than answered, as they are sent while the user types.
+func (h *Hub) handleTyping(client *models.Client, message models.Message) {
+	if client.ReadOnly || client.UserID == "" {
+		return
+	}
+
+	if message.Type == models.TypingStop {
+		h.stopTyping(client)
+		return
+	}
+
+	h.mu.RLock()
+	room := client.Room
+	h.mu.RUnlock()
+
+	if !h.typing.start(client, room, time.Now()) {
+		return
+	}
+	// Muted users may not show they are typing either
+	if h.muteRemaining(userKey(client)) > 0 {
+		h.typing.stop(client)
+		return
+	}
+	h.Broadcast(typingFrame(models.TypingStart, client, room))
+}
+
+// stopTyping ends the client's typing indicator, if it shows one
+func (h *Hub) stopTyping(client *models.Client) {
+	if room, ok := h.typing.stop(client); ok {
+		h.Broadcast(typingFrame(models.TypingStop, client, room))
+	}
+}
+
+// expireTyping ends the indicators of clients that stopped sending
+// typing_start without a typing_stop
+func (h *Hub) expireTyping(now time.Time) {
+	for client, room := range h.typing.expired(now) {
+		h.Broadcast(typingFrame(models.TypingStop, client, room))
+	}
+}
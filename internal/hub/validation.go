@@ -0,0 +1,24 @@
+package hub
+
+import (
+	"fmt"
+
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+// validateFrame checks a client frame against its schema. Stickers must
+// belong to an enabled pack available in the client's room.
+func (h *Hub) validateFrame(client *models.Client, message models.Message) *models.FrameError {
+	if err := models.ValidateClientFrame(message); err != nil {
+		return err
+	}
+	if message.Type == models.StickerMessage {
+		h.mu.RLock()
+		room := client.Room
+		h.mu.RUnlock()
+		if !h.stickers.available(message.Content, room) {
+			return &models.FrameError{Code: models.ErrCodeUnknownSticker, Message: fmt.Sprintf("unknown sticker %q", message.Content)}
+		}
+	}
+	return nil
+}
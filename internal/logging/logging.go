@@ -0,0 +1,116 @@
+// Package logging configures the structured logger shared by the server.
+// Chat content, IP addresses and secrets are redacted from every line
+// unless content logging is explicitly enabled.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+)
+
+// Options configure the logger
+type Options struct {
+	// Level is debug, info, warn or error
+	Level string
+	// Format is json or text
+	Format string
+	// LogContent keeps chat content and full IP addresses in log lines.
+	// Only meant for debugging.
+	LogContent bool
+}
+
+// Attribute keys with special handling
+const (
+	// ContentKey carries chat content, which is redacted by default
+	ContentKey = "content"
+	// IPKey carries client IP addresses, which are masked by default
+	IPKey = "ip"
+)
+
+// secretKeys are always redacted, whatever their value
+var secretKeys = map[string]bool{
+	"token":         true,
+	"authorization": true,
+	"password":      true,
+	"secret":        true,
+	"api_key":       true,
+}
+
+// Setup builds the logger described by opts and makes it the default, so
+// the standard log package writes through it as well
+func Setup(w io.Writer, opts Options) *slog.Logger {
+	handlerOpts := &slog.HandlerOptions{
+		Level: ParseLevel(opts.Level),
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			return redact(a, opts.LogContent)
+		},
+	}
+
+	var handler slog.Handler
+	if strings.EqualFold(opts.Format, "text") {
+		handler = slog.NewTextHandler(w, handlerOpts)
+	} else {
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	}
+
+	logger := slog.New(handler)
+	slog.SetDefault(logger)
+	return logger
+}
+
+// ParseLevel parses a level name, defaulting to info
+func ParseLevel(s string) slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+func redact(a slog.Attr, keepContent bool) slog.Attr {
+	key := strings.ToLower(a.Key)
+	switch {
+	case secretKeys[key]:
+		return slog.String(a.Key, "[redacted]")
+	case keepContent:
+		return a
+	case key == ContentKey:
+		return slog.String(a.Key, fmt.Sprintf("[redacted %d bytes]", len(a.Value.String())))
+	case key == IPKey:
+		return slog.String(a.Key, maskIP(a.Value.String()))
+	}
+	return a
+}
+
+// maskIP drops the host part of an address: the last byte of IPv4 and all
+// but the first 48 bits of IPv6 addresses
+func maskIP(s string) string {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return "[redacted]"
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String() + "/48"
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a context carrying logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger stored in ctx, which carries the request
+// ID, or the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
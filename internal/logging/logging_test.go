@@ -0,0 +1,144 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestMaskIP(t *testing.T) {
+	tests := []struct {
+		name string
+		ip   string
+		want string
+	}{
+		{name: "IPv4", ip: "203.0.113.42", want: "203.0.113.0/24"},
+		{name: "IPv4 mapped IPv6", ip: "::ffff:203.0.113.42", want: "203.0.113.0/24"},
+		{name: "IPv6", ip: "2001:db8:85a3:8d3:1319:8a2e:370:7348", want: "2001:db8:85a3::/48"},
+		{name: "IPv6 loopback", ip: "::1", want: "::/48"},
+		{name: "with port", ip: "203.0.113.42:443", want: "[redacted]"},
+		{name: "not an address", ip: "unknown", want: "[redacted]"},
+		{name: "empty", ip: "", want: "[redacted]"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := maskIP(tc.ip); got != tc.want {
+				t.Errorf("maskIP(%q) = %q, want %q", tc.ip, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name        string
+		attr        slog.Attr
+		keepContent bool
+		want        slog.Attr
+	}{
+		{
+			name: "content",
+			attr: slog.String("content", "vote for me"),
+			want: slog.String("content", "[redacted 11 bytes]"),
+		},
+		{
+			name:        "content kept",
+			attr:        slog.String("content", "vote for me"),
+			keepContent: true,
+			want:        slog.String("content", "vote for me"),
+		},
+		{
+			name: "ip",
+			attr: slog.String("ip", "198.51.100.7"),
+			want: slog.String("ip", "198.51.100.0/24"),
+		},
+		{
+			name:        "ip kept",
+			attr:        slog.String("ip", "198.51.100.7"),
+			keepContent: true,
+			want:        slog.String("ip", "198.51.100.7"),
+		},
+		{
+			name:        "secret even with content kept",
+			attr:        slog.String("token", "eyJhbGciOi"),
+			keepContent: true,
+			want:        slog.String("token", "[redacted]"),
+		},
+		{
+			name: "key case is ignored",
+			attr: slog.String("Authorization", "Bearer abc"),
+			want: slog.String("Authorization", "[redacted]"),
+		},
+		{
+			name: "other keys",
+			attr: slog.Int("count", 3),
+			want: slog.Int("count", 3),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := redact(tc.attr, tc.keepContent); !got.Equal(tc.want) {
+				t.Errorf("redact(%v) = %v, want %v", tc.attr, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSetupRedactsNestedGroups(t *testing.T) {
+	previous := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	tests := []struct {
+		name       string
+		logContent bool
+		want       map[string]interface{}
+	}{
+		{
+			name: "redacted",
+			want: map[string]interface{}{
+				"ip": "2001:db8:1::/48",
+				"message": map[string]interface{}{
+					"content": "[redacted 5 bytes]",
+					"sender":  map[string]interface{}{"ip": "192.0.2.0/24", "api_key": "[redacted]"},
+				},
+			},
+		},
+		{
+			name:       "LOG_CHAT_CONTENT",
+			logContent: true,
+			want: map[string]interface{}{
+				"ip": "2001:db8:1:2::9",
+				"message": map[string]interface{}{
+					"content": "hello",
+					"sender":  map[string]interface{}{"ip": "192.0.2.9", "api_key": "[redacted]"},
+				},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := Setup(&buf, Options{Format: "json", LogContent: tc.logContent})
+			logger.WithGroup("conn").Info("message received",
+				"ip", "2001:db8:1:2::9",
+				slog.Group("message",
+					"content", "hello",
+					slog.Group("sender", "ip", "192.0.2.9", "api_key", "k"),
+				),
+			)
+
+			var line map[string]interface{}
+			if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+				t.Fatalf("decode log line %q: %v", buf.String(), err)
+			}
+			if got := line["conn"]; !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("logged %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,362 @@
+// Package metrics keeps counters, gauges and histograms in memory and
+// serves them in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// collector is a metric family that can write itself in text format
+type collector interface {
+	write(w io.Writer)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []collector
+)
+
+func register(c collector) {
+	registryMu.Lock()
+	registry = append(registry, c)
+	registryMu.Unlock()
+}
+
+// Authorize only lets requests through that carry token as a bearer token,
+// as Prometheus sends it with the bearer_token scrape setting. Metrics are
+// disabled when no token is configured.
+func Authorize(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			http.Error(w, "Metrics are disabled", http.StatusForbidden)
+			return
+		}
+		header := r.Header.Get("Authorization")
+		if !strings.HasPrefix(header, "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(header, "Bearer ")), []byte(token)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Handler serves all registered metrics
+func Handler(w http.ResponseWriter, r *http.Request) {
+	registryMu.Lock()
+	collectors := append([]collector{}, registry...)
+	registryMu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(bw)
+	}
+	bw.Flush()
+}
+
+// Since returns the seconds elapsed since start, for observing durations
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels renders label pairs as {a="x",b="y"}
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// Counter only goes up
+type Counter struct {
+	v atomic.Uint64
+}
+
+func (c *Counter) Inc() {
+	c.v.Add(1)
+}
+
+func (c *Counter) Add(n uint64) {
+	c.v.Add(n)
+}
+
+func (c *Counter) value() uint64 {
+	return c.v.Load()
+}
+
+type counterFamily struct {
+	name, help string
+	counter    *Counter
+}
+
+// NewCounter registers a counter without labels
+func NewCounter(name, help string) *Counter {
+	f := &counterFamily{name: name, help: help, counter: &Counter{}}
+	register(f)
+	return f.counter
+}
+
+func (f *counterFamily) write(w io.Writer) {
+	writeHeader(w, f.name, f.help, "counter")
+	fmt.Fprintf(w, "%s %d\n", f.name, f.counter.value())
+}
+
+// CounterVec is a counter partitioned by labels. Label values must come
+// from a small fixed set.
+type CounterVec struct {
+	name, help string
+	labels     []string
+
+	mu       sync.RWMutex
+	counters map[string]*Counter
+	values   map[string][]string
+}
+
+// NewCounterVec registers a counter with the given label names
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{
+		name:     name,
+		help:     help,
+		labels:   labels,
+		counters: make(map[string]*Counter),
+		values:   make(map[string][]string),
+	}
+	register(v)
+	return v
+}
+
+// With returns the counter for the label values, in label order
+func (v *CounterVec) With(values ...string) *Counter {
+	key := strings.Join(values, "\xff")
+
+	v.mu.RLock()
+	c, ok := v.counters[key]
+	v.mu.RUnlock()
+	if ok {
+		return c
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if c, ok := v.counters[key]; ok {
+		return c
+	}
+	c = &Counter{}
+	v.counters[key] = c
+	v.values[key] = append([]string{}, values...)
+	return c
+}
+
+func (v *CounterVec) write(w io.Writer) {
+	writeHeader(w, v.name, v.help, "counter")
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	for _, key := range sortedKeys(v.values) {
+		fmt.Fprintf(w, "%s%s %d\n", v.name, formatLabels(v.labels, v.values[key]), v.counters[key].value())
+	}
+}
+
+// Gauge can go up and down
+type Gauge struct {
+	v atomic.Int64
+}
+
+func (g *Gauge) Set(n int64) {
+	g.v.Store(n)
+}
+
+func (g *Gauge) Inc() {
+	g.v.Add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.v.Add(-1)
+}
+
+type gaugeFamily struct {
+	name, help string
+	gauge      *Gauge
+}
+
+// NewGauge registers a gauge without labels
+func NewGauge(name, help string) *Gauge {
+	f := &gaugeFamily{name: name, help: help, gauge: &Gauge{}}
+	register(f)
+	return f.gauge
+}
+
+func (f *gaugeFamily) write(w io.Writer) {
+	writeHeader(w, f.name, f.help, "gauge")
+	fmt.Fprintf(w, "%s %d\n", f.name, f.gauge.v.Load())
+}
+
+type gaugeFunc struct {
+	name, help string
+	fn         func() float64
+}
+
+// NewGaugeFunc registers a gauge whose value is read from fn on every
+// scrape
+func NewGaugeFunc(name, help string, fn func() float64) {
+	register(&gaugeFunc{name: name, help: help, fn: fn})
+}
+
+func (g *gaugeFunc) write(w io.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.fn()))
+}
+
+// DefBuckets suit request latencies in seconds, from 0.5ms to 10s
+var DefBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram counts observations in cumulative buckets
+type Histogram struct {
+	upper  []float64
+	counts []atomic.Uint64
+	count  atomic.Uint64
+	sum    atomic.Uint64 // float64 bits
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{upper: buckets, counts: make([]atomic.Uint64, len(buckets))}
+}
+
+// Observe records a value
+func (h *Histogram) Observe(v float64) {
+	if i := sort.SearchFloat64s(h.upper, v); i < len(h.upper) {
+		h.counts[i].Add(1)
+	}
+	h.count.Add(1)
+	for {
+		old := h.sum.Load()
+		if h.sum.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+func (h *Histogram) write(w io.Writer, name string, labelNames, labelValues []string) {
+	names := append(append([]string{}, labelNames...), "le")
+	var cumulative uint64
+	for i, upper := range h.upper {
+		cumulative += h.counts[i].Load()
+		values := append(append([]string{}, labelValues...), formatFloat(upper))
+		fmt.Fprintf(w, "%s_bucket%s %d\n", name, formatLabels(names, values), cumulative)
+	}
+	count := h.count.Load()
+	values := append(append([]string{}, labelValues...), "+Inf")
+	fmt.Fprintf(w, "%s_bucket%s %d\n", name, formatLabels(names, values), count)
+
+	labels := formatLabels(labelNames, labelValues)
+	fmt.Fprintf(w, "%s_sum%s %s\n", name, labels, formatFloat(math.Float64frombits(h.sum.Load())))
+	fmt.Fprintf(w, "%s_count%s %d\n", name, labels, count)
+}
+
+// HistogramVec is a histogram partitioned by labels. Label values must
+// come from a small fixed set.
+type HistogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+
+	mu         sync.RWMutex
+	histograms map[string]*Histogram
+	values     map[string][]string
+}
+
+// NewHistogramVec registers a histogram with the given upper bucket
+// bounds, in increasing order, and label names
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	v := &HistogramVec{
+		name:       name,
+		help:       help,
+		labels:     labels,
+		buckets:    buckets,
+		histograms: make(map[string]*Histogram),
+		values:     make(map[string][]string),
+	}
+	register(v)
+	return v
+}
+
+// With returns the histogram for the label values, in label order
+func (v *HistogramVec) With(values ...string) *Histogram {
+	key := strings.Join(values, "\xff")
+
+	v.mu.RLock()
+	h, ok := v.histograms[key]
+	v.mu.RUnlock()
+	if ok {
+		return h
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if h, ok := v.histograms[key]; ok {
+		return h
+	}
+	h = newHistogram(v.buckets)
+	v.histograms[key] = h
+	v.values[key] = append([]string{}, values...)
+	return h
+}
+
+func (v *HistogramVec) write(w io.Writer) {
+	writeHeader(w, v.name, v.help, "histogram")
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	for _, key := range sortedKeys(v.values) {
+		v.histograms[key].write(w, v.name, v.labels, v.values[key])
+	}
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,31 @@
+package metrics
+
+// Metrics of the chat server. Gauges read from the hub are registered by
+// the hub itself.
+var (
+	Connections = NewCounter("pollz_connections_total",
+		"WebSocket connections registered with the hub.")
+	Disconnections = NewCounterVec("pollz_disconnections_total",
+		"Connections removed from the hub, by reason.", "reason")
+
+	MessagesReceived = NewCounterVec("pollz_messages_received_total",
+		"Valid frames received from clients, by type.", "type")
+	FramesRejected = NewCounterVec("pollz_frames_rejected_total",
+		"Client frames answered with an error frame, by error code.", "code")
+	MessagesBroadcast = NewCounterVec("pollz_messages_broadcast_total",
+		"Messages broadcast to rooms by this instance, by type.", "type")
+	MessagesDropped = NewCounterVec("pollz_messages_dropped_total",
+		"Messages not delivered because the client's send buffer was full, by type.", "type")
+	SlowClientEvictions = NewCounter("pollz_slow_client_evictions_total",
+		"Clients disconnected because their send buffer stayed full past the grace window.")
+
+	CensoredMessages = NewCounter("pollz_censored_messages_total",
+		"Messages changed by the profanity filter.")
+	RateLimited = NewCounterVec("pollz_rate_limited_total",
+		"Client actions rejected by mutes, slow mode or rate limits, by limit.", "limit")
+
+	RedisDuration = NewHistogramVec("pollz_redis_command_duration_seconds",
+		"Latency of Redis commands, by command.", DefBuckets, "command")
+	PostgresDuration = NewHistogramVec("pollz_postgres_duration_seconds",
+		"Latency of Postgres round trips, by operation.", DefBuckets, "operation")
+)
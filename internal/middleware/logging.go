@@ -3,12 +3,18 @@ package middleware
 import (
 	"bufio"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"time"
+
+	"github.com/google/uuid"
+	"github.com/pollz/websocket-server/internal/logging"
 )
 
+// maxRequestIDLength bounds request IDs accepted from upstream proxies
+const maxRequestIDLength = 64
+
 type responseWriter struct {
 	http.ResponseWriter
 	status int
@@ -34,26 +40,43 @@ func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	return nil, nil, fmt.Errorf("responseWriter does not implement http.Hijacker")
 }
 
-// Logging middleware logs all HTTP requests
+// Logging middleware logs all HTTP requests. Each request gets an ID, taken
+// from the X-Request-ID header if a proxy set one, which is returned in the
+// response and attached to every line logged through the request context.
 func Logging(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		
+
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = uuid.New().String()
+		}
+		w.Header().Set("X-Request-ID", requestID)
+		logger := slog.Default().With("request_id", requestID)
+
 		wrapped := &responseWriter{
 			ResponseWriter: w,
-			status:        200,
+			status:         200,
 		}
-		
-		next.ServeHTTP(wrapped, r)
-		
-		log.Printf(
-			"%s %s %s %d %d %v",
-			r.RemoteAddr,
-			r.Method,
-			r.URL.Path,
-			wrapped.status,
-			wrapped.size,
-			time.Since(start),
+
+		next.ServeHTTP(wrapped, r.WithContext(logging.WithLogger(r.Context(), logger)))
+
+		logger.Info("http request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", wrapped.status,
+			"bytes", wrapped.size,
+			"duration", time.Since(start),
+			logging.IPKey, remoteIP(r),
 		)
 	})
+}
+
+// remoteIP returns the address of the peer that sent the request
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
 }
\ No newline at end of file
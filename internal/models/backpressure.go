@@ -0,0 +1,30 @@
+package models
+
+// BackpressurePolicy decides what happens to a client whose send buffer is
+// full because it reads slower than messages arrive
+type BackpressurePolicy string
+
+const (
+	// DropOldest discards the oldest queued message to make room for the
+	// new one
+	DropOldest BackpressurePolicy = "drop_oldest"
+
+	// DropLowPriority discards droppable messages, such as reactions, first
+	// and only falls back to dropping the oldest message when none are
+	// queued
+	DropLowPriority BackpressurePolicy = "drop_low_priority"
+
+	// DisconnectSlow skips new messages and disconnects the client once the
+	// grace window is over
+	DisconnectSlow BackpressurePolicy = "disconnect"
+)
+
+// ParseBackpressurePolicy returns the policy named by s, or an empty policy
+// if s names none, so the server default applies
+func ParseBackpressurePolicy(s string) BackpressurePolicy {
+	switch BackpressurePolicy(s) {
+	case DropOldest, DropLowPriority, DisconnectSlow:
+		return BackpressurePolicy(s)
+	}
+	return ""
+}
@@ -1,23 +1,80 @@
 package models
 
 import (
+	"log/slog"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+// Close codes sent when the server ends a connection for moderation
+const (
+	CloseBanned = 4003
+	CloseKicked = 4004
+)
+
 type Client struct {
-	ID         string
-	Hub        Hub
-	Conn       *websocket.Conn
-	Send       chan Message
-	UserID     string
-	Username   string
-	JoinedAt   time.Time
+	ID       string
+	Hub      Hub
+	Conn     *websocket.Conn
+	Send     chan Message
+	UserID   string
+	Username string
+	JoinedAt time.Time
+
+	// ReadOnly clients connected without a token; they receive messages but
+	// cannot send them.
+	ReadOnly bool
+
+	// Role decides whether the client may send moderation commands; IP is
+	// the address it connected from
+	Role Role
+	IP   string
+
+	// CloseCode and CloseReason are sent in the close frame once the hub
+	// closes Send. A zero code sends a plain close frame.
+	CloseCode   int
+	CloseReason string
+
+	// Room is the room the client currently belongs to. It is set before
+	// Register and afterwards only changed by the hub.
+	Room string
+
+	// Polls are the polls the client asked to follow when connecting, and
+	// LastSeq the last sequence number it received before reconnecting.
+	// They are only read by Register.
+	Polls   []string
+	LastSeq int64
+
+	// Backpressure is the policy applied when Send is full. An empty policy
+	// uses the server default.
+	Backpressure BackpressurePolicy
+
+	// Log carries the connection's request and connection IDs
+	Log *slog.Logger
+}
+
+// ClientInfo describes a connected client for the admin API
+type ClientInfo struct {
+	ID       string    `json:"id"`
+	UserID   string    `json:"user_id,omitempty"`
+	Username string    `json:"username"`
+	IP       string    `json:"ip"`
+	Room     string    `json:"room"`
+	Role     Role      `json:"role"`
+	ReadOnly bool      `json:"read_only"`
+	JoinedAt time.Time `json:"joined_at"`
 }
 
 type Hub interface {
 	Register(client *Client)
 	Unregister(client *Client)
 	Broadcast(message Message)
-}
\ No newline at end of file
+
+	// Receive handles a frame read from the client's connection
+	Receive(client *Client, message Message)
+
+	// Reject sends the client an error frame for a frame that could not
+	// be read
+	Reject(client *Client, code, text string)
+}
@@ -0,0 +1,15 @@
+package models
+
+import "errors"
+
+// ErrInvalidInput is wrapped by errors caused by bad client or admin input,
+// as opposed to storage failures
+var ErrInvalidInput = errors.New("invalid input")
+
+// ErrNotFound is wrapped by errors for clients, messages or bans that do
+// not exist
+var ErrNotFound = errors.New("not found")
+
+// ErrForbidden is wrapped by errors for moderation commands aimed at a
+// user with the same or a higher role than the moderator
+var ErrForbidden = errors.New("forbidden")
@@ -0,0 +1,36 @@
+package models
+
+import "errors"
+
+// ErrEventInProgress is returned when an event with the same idempotency key
+// is still being handled
+var ErrEventInProgress = errors.New("event with this idempotency key is in progress")
+
+// Event types accepted from the backend
+const (
+	EventSystem       = "system"
+	EventAnnouncement = "announcement"
+	EventSuperChat    = "superchat"
+	EventPollResults  = "poll_results"
+)
+
+// Event is a message or update pushed by the Pollz backend. Retries must
+// reuse the IdempotencyKey so the event is only published once.
+type Event struct {
+	IdempotencyKey string         `json:"idempotency_key"`
+	Type           string         `json:"type"`
+	Room           string         `json:"room"`
+	Message        string         `json:"message"`
+	UserID         string         `json:"user_id,omitempty"`
+	Username       string         `json:"username,omitempty"`
+	PaymentID      string         `json:"payment_id,omitempty"`
+	SuperChat      *SuperChatInfo `json:"superchat,omitempty"`
+	Poll           *PollTally     `json:"poll,omitempty"`
+}
+
+// EventResult is returned for an ingested event. Duplicate is set when the
+// idempotency key was seen before and nothing was published.
+type EventResult struct {
+	MessageID string `json:"message_id,omitempty"`
+	Duplicate bool   `json:"duplicate"`
+}
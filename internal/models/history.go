@@ -0,0 +1,58 @@
+package models
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxHistoryPage bounds the number of messages in a history page
+const MaxHistoryPage = 100
+
+// Cursor marks a position in a room's history. Messages are ordered by
+// creation time and then ID, so a cursor is unique even when several
+// messages share a timestamp.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// CursorOf returns the cursor positioned at a message
+func CursorOf(msg Message) Cursor {
+	return Cursor{CreatedAt: msg.CreatedAt, ID: msg.ID}
+}
+
+// String encodes the cursor as an opaque token. Timestamps keep the
+// microsecond precision of the database.
+func (c Cursor) String() string {
+	raw := strconv.FormatInt(c.CreatedAt.UnixMicro(), 10) + ":" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseCursor decodes a token returned by Cursor.String
+func ParseCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("%w: invalid cursor", ErrInvalidInput)
+	}
+	micros, id, ok := strings.Cut(string(raw), ":")
+	if !ok || id == "" {
+		return Cursor{}, fmt.Errorf("%w: invalid cursor", ErrInvalidInput)
+	}
+	n, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("%w: invalid cursor", ErrInvalidInput)
+	}
+	return Cursor{CreatedAt: time.UnixMicro(n).UTC(), ID: id}, nil
+}
+
+// HistoryPage is a page of a room's messages, oldest first. NextCursor
+// continues in the direction the page was requested and is empty once
+// there are no more messages.
+type HistoryPage struct {
+	Room       string    `json:"room"`
+	Messages   []Message `json:"messages"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
@@ -4,13 +4,71 @@ import (
 	"time"
 )
 
+// DefaultRoom is the room used when a client does not ask for one.
+const DefaultRoom = "live"
+
+// maxRoomNameLength bounds room names so they stay usable as Redis keys.
+const maxRoomNameLength = 64
+
 type Message struct {
-	ID        string    `json:"id"`
-	Content   string    `json:"message"`
+	ID        string      `json:"id"`
+	Content   string      `json:"message"`
 	Type      MessageType `json:"type"`
-	UserID    string    `json:"user_id,omitempty"`
-	Username  string    `json:"username,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
+	Room      string      `json:"room,omitempty"`
+	UserID    string      `json:"user_id,omitempty"`
+	Username  string      `json:"username,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+
+	// Seq numbers the broadcasts of a room in order. Reconnecting clients
+	// pass the last one they received to have missed broadcasts replayed.
+	Seq int64 `json:"seq,omitempty"`
+
+	// RetryAfter asks the client to reconnect after this many seconds
+	RetryAfter int `json:"retry_after,omitempty"`
+
+	// Code identifies the problem reported by error frames
+	Code string `json:"code,omitempty"`
+
+	// ReplyTo is the ID of the message this one replies to. ReplyPreview
+	// quotes that message and is filled in by the server.
+	ReplyTo      string        `json:"reply_to,omitempty"`
+	ReplyPreview *ReplyPreview `json:"reply_preview,omitempty"`
+
+	// Reaction frames name the message reacted to, the emoji and whether it
+	// is added or removed. Deltas sent by the server carry the emoji's new
+	// count in Reactions; messages in recent_messages carry all their counts.
+	MessageID string         `json:"message_id,omitempty"`
+	Emoji     string         `json:"emoji,omitempty"`
+	Action    string         `json:"action,omitempty"`
+	Reactions ReactionCounts `json:"reactions,omitempty"`
+
+	// Command is set on moderation frames
+	Command *ModCommand `json:"command,omitempty"`
+
+	// Pinned is the pinned message carried by message_pinned events
+	Pinned *Message `json:"pinned,omitempty"`
+
+	// SuperChat carries the payment of superchat messages
+	SuperChat *SuperChatInfo `json:"superchat,omitempty"`
+
+	// PollID names the poll of subscribe frames; Poll is the snapshot
+	// carried by poll_update frames
+	PollID string     `json:"poll_id,omitempty"`
+	Poll   *PollTally `json:"poll,omitempty"`
+
+	// History requests name the cursor to page back from and the page
+	// size; History is the page sent in reply
+	Before  string       `json:"before,omitempty"`
+	Limit   int          `json:"limit,omitempty"`
+	History *HistoryPage `json:"history,omitempty"`
+
+	// Snapshot is the room state carried by recent_messages frames
+	Snapshot *RoomSnapshot `json:"snapshot,omitempty"`
+
+	// Presence frames carry the number of connections watching the room
+	// and, when sent to a client that just joined, its roster
+	Online int            `json:"online,omitempty"`
+	Users  []PresenceUser `json:"users,omitempty"`
 }
 
 type MessageType string
@@ -20,9 +78,128 @@ const (
 	StickerMessage MessageType = "sticker"
 	SystemMessage  MessageType = "system"
 	SuperChat      MessageType = "superchat"
+
+	// Announcement is an official announcement, e.g. election results,
+	// published by the backend
+	Announcement MessageType = "announcement"
+
+	// JoinRoom is sent by a client to move its connection to another room
+	JoinRoom MessageType = "join"
+
+	// Reaction adds or removes an emoji on an existing message
+	Reaction MessageType = "reaction"
+
+	// Moderation carries a command from a moderator; MessageDeleted tells
+	// clients to remove a message
+	Moderation     MessageType = "moderation"
+	MessageDeleted MessageType = "message_deleted"
+
+	// Room events sent when an administrator clears a room or pins a
+	// message
+	MessagesCleared MessageType = "messages_cleared"
+	MessagePinned   MessageType = "message_pinned"
+	MessageUnpinned MessageType = "message_unpinned"
+
+	// Clients follow polls with poll_subscribe and poll_unsubscribe frames
+	// and receive poll_update snapshots of their vote counts
+	PollSubscribe   MessageType = "poll_subscribe"
+	PollUnsubscribe MessageType = "poll_unsubscribe"
+	PollUpdate      MessageType = "poll_update"
+
+	// RecentMessages gives a client the state of the room it connected to
+	// or joined
+	RecentMessages MessageType = "recent_messages"
+
+	// HistoryRequest asks for an older page of the current room; the page is
+	// sent back in a history frame
+	HistoryRequest MessageType = "history_request"
+	History        MessageType = "history"
+
+	// Resumed follows the broadcasts replayed to a reconnecting client;
+	// ResumeGap tells it they could not be replayed and a recent_messages
+	// snapshot follows instead
+	Resumed   MessageType = "resumed"
+	ResumeGap MessageType = "resume_gap"
+
+	// ErrorMessage tells a client why its frame was rejected
+	ErrorMessage MessageType = "error"
+
+	// Lagging tells a client that reads too slowly that messages are being
+	// skipped and it will be disconnected unless it catches up
+	Lagging MessageType = "lagging"
+
+	// Clients send typing_start while the user types and typing_stop when
+	// they stop; both are relayed to the room
+	TypingStart MessageType = "typing_start"
+	TypingStop  MessageType = "typing_stop"
+
+	// UserJoined and UserLeft announce signed-in users entering and leaving
+	// a room; Presence carries the room's online count
+	UserJoined MessageType = "user_joined"
+	UserLeft   MessageType = "user_left"
+	Presence   MessageType = "presence"
 )
 
-type RecentMessagesResponse struct {
-	Type     string    `json:"type"`
+// Droppable reports whether messages of this type may be skipped for a
+// client that falls behind: they only decorate other messages or are
+// superseded by later ones
+func (t MessageType) Droppable() bool {
+	switch t {
+	case Reaction, PollUpdate, TypingStart, TypingStop, Presence:
+		return true
+	}
+	return false
+}
+
+// Ephemeral reports whether messages of this type only matter to the
+// clients connected when they are sent. They are relayed but neither
+// numbered, replayed nor stored.
+func (t MessageType) Ephemeral() bool {
+	switch t {
+	case TypingStart, TypingStop, UserJoined, UserLeft, Presence:
+		return true
+	}
+	return false
+}
+
+// IsEvent reports whether messages of this type describe changes to other
+// messages. Events are relayed to clients but not stored.
+func (t MessageType) IsEvent() bool {
+	switch t {
+	case Reaction, MessageDeleted, MessagesCleared, MessagePinned, MessageUnpinned:
+		return true
+	}
+	return false
+}
+
+// RoomSnapshot is the state of a room sent in recent_messages frames to
+// clients that connect to or join it
+type RoomSnapshot struct {
 	Messages []Message `json:"messages"`
-}
\ No newline at end of file
+	Pinned   *Message  `json:"pinned,omitempty"`
+
+	// SuperChats are the superchats currently pinned in the room
+	SuperChats []Message `json:"superchats,omitempty"`
+
+	// NextCursor pages back from the oldest message with history_request
+	NextCursor string `json:"next_cursor,omitempty"`
+
+	// Seq is the room's latest sequence number; clients resume from it
+	Seq int64 `json:"seq"`
+}
+
+// ValidRoom reports whether name can be used as a room name. Room names are
+// limited to letters, digits, '-' and '_'.
+func ValidRoom(name string) bool {
+	if name == "" || len(name) > maxRoomNameLength {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
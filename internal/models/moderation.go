@@ -0,0 +1,107 @@
+package models
+
+import "time"
+
+// Role is a user's privilege level, taken from the access token
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// ParseRole returns the role named by s, defaulting to RoleUser
+func ParseRole(s string) Role {
+	switch Role(s) {
+	case RoleModerator, RoleAdmin:
+		return Role(s)
+	}
+	return RoleUser
+}
+
+// CanModerate reports whether the role may send moderation commands
+func (r Role) CanModerate() bool {
+	return r == RoleModerator || r == RoleAdmin
+}
+
+// Outranks reports whether r has more privileges than other. Moderators
+// may only ban or kick users they outrank.
+func (r Role) Outranks(other Role) bool {
+	return r.rank() > other.rank()
+}
+
+func (r Role) rank() int {
+	switch r {
+	case RoleAdmin:
+		return 2
+	case RoleModerator:
+		return 1
+	}
+	return 0
+}
+
+// Moderation command actions
+const (
+	ModDelete = "delete"
+	ModMute   = "mute"
+	ModUnmute = "unmute"
+	ModBan    = "ban"
+	ModUnban  = "unban"
+	ModKick   = "kick"
+)
+
+// Actions recorded in the audit log for admin API operations
+const (
+	ModDisconnect = "disconnect"
+	ModAnnounce   = "announce"
+	ModClear      = "clear"
+	ModPin        = "pin"
+	ModUnpin      = "unpin"
+)
+
+// ModCommand is the body of a moderation frame. MessageID is used by
+// delete, UserID and IP by the other actions, and a kick with a ClientID
+// closes that connection only; Duration is in seconds and a ban without
+// one is permanent.
+type ModCommand struct {
+	Action    string `json:"action"`
+	MessageID string `json:"message_id,omitempty"`
+	UserID    string `json:"user_id,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	IP        string `json:"ip,omitempty"`
+	Duration  int    `json:"duration,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// Ban keeps a user ID or IP address from connecting until it expires or
+// is lifted. A nil ExpiresAt never expires.
+type Ban struct {
+	ID        int64      `json:"id"`
+	UserID    string     `json:"user_id,omitempty"`
+	IP        string     `json:"ip,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+	CreatedBy string     `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Mute is a user who cannot send messages until ExpiresAt
+type Mute struct {
+	UserID    string    `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ModerationAction is an entry in the moderation audit log
+type ModerationAction struct {
+	ID          int64     `json:"id"`
+	Action      string    `json:"action"`
+	ModeratorID string    `json:"moderator_id"`
+	UserID      string    `json:"user_id,omitempty"`
+	IP          string    `json:"ip,omitempty"`
+	MessageID   string    `json:"message_id,omitempty"`
+	Room        string    `json:"room,omitempty"`
+	Duration    int       `json:"duration,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// PollTally is a snapshot of a poll's vote counts pushed by the backend.
+// Counts maps option IDs to votes.
+type PollTally struct {
+	PollID     string           `json:"poll_id"`
+	Counts     map[string]int64 `json:"counts"`
+	TotalVotes int64            `json:"total_votes"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+}
+
+// ValidPollID reports whether id can be used as a poll ID. Poll IDs follow
+// the same rules as room names.
+func ValidPollID(id string) bool {
+	return ValidRoom(id)
+}
@@ -0,0 +1,11 @@
+package models
+
+// MaxRosterUsers bounds the users listed in presence frames. Rooms with
+// more signed-in users are still counted in full.
+const MaxRosterUsers = 100
+
+// PresenceUser is a signed-in user listed in a room's roster
+type PresenceUser struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// MatchMode controls how a profanity rule's word is compared with a token
+type MatchMode string
+
+const (
+	// ExactMatch matches tokens equal to the word
+	ExactMatch MatchMode = "exact"
+	// PrefixMatch matches tokens starting with the word
+	PrefixMatch MatchMode = "prefix"
+	// SubstringMatch matches tokens containing the word anywhere
+	SubstringMatch MatchMode = "substring"
+)
+
+// Valid reports whether m is a known match mode
+func (m MatchMode) Valid() bool {
+	switch m {
+	case ExactMatch, PrefixMatch, SubstringMatch:
+		return true
+	}
+	return false
+}
+
+// ProfanityRule is one entry of the moderation word list. Allow rules are
+// exceptions that keep a token from being censored by any other rule.
+type ProfanityRule struct {
+	ID        int64     `json:"id"`
+	Word      string    `json:"word"`
+	Mode      MatchMode `json:"mode"`
+	Room      string    `json:"room,omitempty"` // empty applies to all rooms
+	Allow     bool      `json:"allow"`
+	CreatedAt time.Time `json:"created_at"`
+}
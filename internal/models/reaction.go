@@ -0,0 +1,37 @@
+package models
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxEmojiLength bounds reactions in bytes; it fits the longest ZWJ
+// sequences such as family and flag emoji
+const maxEmojiLength = 32
+
+// Reaction frame actions
+const (
+	ReactionAdd    = "add"
+	ReactionRemove = "remove"
+)
+
+// ReactionCounts maps an emoji to the number of users who reacted with it
+type ReactionCounts map[string]int
+
+// ValidEmoji reports whether s can be used as a reaction. It must contain
+// at least one symbol and no letters, digits, spaces or control characters.
+func ValidEmoji(s string) bool {
+	if s == "" || len(s) > maxEmojiLength || !utf8.ValidString(s) {
+		return false
+	}
+	hasSymbol := false
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), unicode.IsSpace(r), unicode.IsControl(r):
+			return false
+		case unicode.Is(unicode.So, r):
+			hasSymbol = true
+		}
+	}
+	return hasSymbol
+}
@@ -0,0 +1,41 @@
+package models
+
+import "unicode/utf8"
+
+// maxPreviewLength is the number of characters of the parent message
+// quoted in a reply preview
+const maxPreviewLength = 100
+
+// ReplyPreview quotes the start of the message a reply refers to, so
+// clients can render replies without fetching the parent
+type ReplyPreview struct {
+	ID       string `json:"id"`
+	Username string `json:"username,omitempty"`
+	Content  string `json:"message"`
+}
+
+// NewReplyPreview builds the preview of parent
+func NewReplyPreview(parent Message) *ReplyPreview {
+	return &ReplyPreview{
+		ID:       parent.ID,
+		Username: parent.Username,
+		Content:  truncate(parent.Content, maxPreviewLength),
+	}
+}
+
+// Thread is a message with a page of the replies to it
+type Thread struct {
+	Parent  Message   `json:"parent"`
+	Replies []Message `json:"replies"`
+	Total   int       `json:"total"`
+}
+
+// truncate shortens s to at most n characters, marking the cut with an
+// ellipsis
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:n-1]) + "…"
+}
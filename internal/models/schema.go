@@ -0,0 +1,186 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Codes of the error frames sent to clients whose frames are rejected
+const (
+	ErrCodeInvalidFrame   = "invalid_frame"
+	ErrCodeUnknownType    = "unknown_type"
+	ErrCodeForbiddenType  = "forbidden_type"
+	ErrCodeMissingField   = "missing_field"
+	ErrCodeInvalidField   = "invalid_field"
+	ErrCodeContentTooLong = "content_too_long"
+	ErrCodeUnknownSticker = "unknown_sticker"
+)
+
+// FrameError describes why a client frame was rejected. It is sent back
+// to the client as an error frame.
+type FrameError struct {
+	Code    string
+	Message string
+}
+
+func (e *FrameError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func frameErrorf(code, format string, args ...interface{}) *FrameError {
+	return &FrameError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// FrameSchema describes a frame type clients may send
+type FrameSchema struct {
+	// MaxContent bounds the message text in characters. Types with a zero
+	// MaxContent carry no text.
+	MaxContent int
+
+	// Required lists the JSON fields that must be set
+	Required []string
+
+	// Check validates the values of the frame's fields
+	Check func(Message) *FrameError
+}
+
+// clientSchemas lists the frame types clients may send. Every other type
+// is only sent by the server.
+var clientSchemas = map[MessageType]FrameSchema{
+	TextMessage: {
+		MaxContent: 1000,
+		Required:   []string{"message"},
+	},
+	StickerMessage: {
+		MaxContent: maxStickerIDLength,
+		Required:   []string{"message"},
+	},
+	JoinRoom: {
+		Required: []string{"room"},
+		Check: func(msg Message) *FrameError {
+			if !ValidRoom(msg.Room) {
+				return frameErrorf(ErrCodeInvalidField, "invalid room %q", msg.Room)
+			}
+			return nil
+		},
+	},
+	Reaction: {
+		Required: []string{"message_id", "emoji"},
+		Check: func(msg Message) *FrameError {
+			if msg.Action != "" && msg.Action != ReactionAdd && msg.Action != ReactionRemove {
+				return frameErrorf(ErrCodeInvalidField, "unknown action %q", msg.Action)
+			}
+			if !ValidEmoji(msg.Emoji) {
+				return frameErrorf(ErrCodeInvalidField, "invalid emoji %q", msg.Emoji)
+			}
+			return nil
+		},
+	},
+	Moderation: {
+		Required: []string{"command"},
+	},
+	PollSubscribe: {
+		Required: []string{"poll_id"},
+		Check:    checkPollID,
+	},
+	PollUnsubscribe: {
+		Required: []string{"poll_id"},
+		Check:    checkPollID,
+	},
+	HistoryRequest: {
+		Check: func(msg Message) *FrameError {
+			if msg.Limit < 0 || msg.Limit > MaxHistoryPage {
+				return frameErrorf(ErrCodeInvalidField, "limit must be between 1 and %d", MaxHistoryPage)
+			}
+			if msg.Before != "" {
+				if _, err := ParseCursor(msg.Before); err != nil {
+					return frameErrorf(ErrCodeInvalidField, "invalid before cursor")
+				}
+			}
+			return nil
+		},
+	},
+	TypingStart: {},
+	TypingStop:  {},
+}
+
+// serverTypes are the frame types only the server may send
+var serverTypes = map[MessageType]bool{
+	SystemMessage:   true,
+	SuperChat:       true,
+	Announcement:    true,
+	MessageDeleted:  true,
+	MessagesCleared: true,
+	MessagePinned:   true,
+	MessageUnpinned: true,
+	PollUpdate:      true,
+	RecentMessages:  true,
+	History:         true,
+	Resumed:         true,
+	ResumeGap:       true,
+	ErrorMessage:    true,
+	Lagging:         true,
+	UserJoined:      true,
+	UserLeft:        true,
+	Presence:        true,
+}
+
+func checkPollID(msg Message) *FrameError {
+	if !ValidPollID(msg.PollID) {
+		return frameErrorf(ErrCodeInvalidField, "invalid poll_id %q", msg.PollID)
+	}
+	return nil
+}
+
+// ValidateClientFrame checks a frame read from a client against the schema
+// of its type
+func ValidateClientFrame(msg Message) *FrameError {
+	schema, ok := clientSchemas[msg.Type]
+	if !ok {
+		if serverTypes[msg.Type] {
+			return frameErrorf(ErrCodeForbiddenType, "clients cannot send %s frames", msg.Type)
+		}
+		return frameErrorf(ErrCodeUnknownType, "unknown frame type %q", msg.Type)
+	}
+
+	for _, field := range schema.Required {
+		if !hasField(msg, field) {
+			return frameErrorf(ErrCodeMissingField, "%s frames need %s", msg.Type, field)
+		}
+	}
+
+	if !utf8.ValidString(msg.Content) {
+		return frameErrorf(ErrCodeInvalidField, "message is not valid UTF-8")
+	}
+	if n := utf8.RuneCountInString(msg.Content); n > schema.MaxContent {
+		if schema.MaxContent == 0 {
+			return frameErrorf(ErrCodeInvalidField, "%s frames carry no message", msg.Type)
+		}
+		return frameErrorf(ErrCodeContentTooLong, "message is %d characters, at most %d are allowed", n, schema.MaxContent)
+	}
+
+	if schema.Check != nil {
+		return schema.Check(msg)
+	}
+	return nil
+}
+
+// hasField reports whether the frame sets the field with the given JSON name
+func hasField(msg Message, field string) bool {
+	switch field {
+	case "message":
+		return strings.TrimSpace(msg.Content) != ""
+	case "room":
+		return msg.Room != ""
+	case "message_id":
+		return msg.MessageID != ""
+	case "emoji":
+		return msg.Emoji != ""
+	case "command":
+		return msg.Command != nil
+	case "poll_id":
+		return msg.PollID != ""
+	}
+	return false
+}
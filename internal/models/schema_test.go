@@ -0,0 +1,74 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateClientFrame(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  Message
+		// want is the error code, empty for a valid frame
+		want string
+	}{
+		{name: "text", msg: Message{Type: TextMessage, Content: "hello"}},
+		{name: "text at the limit", msg: Message{Type: TextMessage, Content: strings.Repeat("a", 1000)}},
+		{
+			name: "oversized text",
+			msg:  Message{Type: TextMessage, Content: strings.Repeat("a", 1001)},
+			want: ErrCodeContentTooLong,
+		},
+		{
+			name: "limit counts characters, not bytes",
+			msg:  Message{Type: TextMessage, Content: strings.Repeat("है", 500)},
+		},
+		{
+			name: "oversized sticker ID",
+			msg:  Message{Type: StickerMessage, Content: strings.Repeat("s", maxStickerIDLength+1)},
+			want: ErrCodeContentTooLong,
+		},
+		{
+			name: "text on a type without text",
+			msg:  Message{Type: TypingStart, Content: "hi"},
+			want: ErrCodeInvalidField,
+		},
+		{name: "unknown type", msg: Message{Type: "shout", Content: "hi"}, want: ErrCodeUnknownType},
+		{name: "empty type", msg: Message{Content: "hi"}, want: ErrCodeUnknownType},
+		{name: "server type", msg: Message{Type: Announcement, Content: "hi"}, want: ErrCodeForbiddenType},
+		{
+			name: "invalid UTF-8",
+			msg:  Message{Type: TextMessage, Content: "caf\xe9"},
+			want: ErrCodeInvalidField,
+		},
+		{name: "blank text", msg: Message{Type: TextMessage, Content: "   "}, want: ErrCodeMissingField},
+		{name: "join", msg: Message{Type: JoinRoom, Room: "debate-2024_1"}},
+		{name: "join without room", msg: Message{Type: JoinRoom}, want: ErrCodeMissingField},
+		{name: "join bad room", msg: Message{Type: JoinRoom, Room: "../admin"}, want: ErrCodeInvalidField},
+		{name: "join room with spaces", msg: Message{Type: JoinRoom, Room: "live chat"}, want: ErrCodeInvalidField},
+		{
+			name: "reaction with unknown action",
+			msg:  Message{Type: Reaction, MessageID: "m1", Emoji: "👍", Action: "toggle"},
+			want: ErrCodeInvalidField,
+		},
+		{name: "history limit too large", msg: Message{Type: HistoryRequest, Limit: MaxHistoryPage + 1}, want: ErrCodeInvalidField},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateClientFrame(tc.msg)
+			if tc.want == "" {
+				if err != nil {
+					t.Fatalf("ValidateClientFrame = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("ValidateClientFrame = nil, want %s", tc.want)
+			}
+			if err.Code != tc.want {
+				t.Errorf("ValidateClientFrame code = %s (%s), want %s", err.Code, err.Message, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// MaxSearchPage bounds the number of results in a search page
+const MaxSearchPage = 100
+
+// SearchQuery selects messages for full-text search. Text may contain
+// "quoted phrases" and words ending in * to match prefixes. Empty filters
+// match everything.
+type SearchQuery struct {
+	Text   string
+	Room   string
+	UserID string
+	Type   MessageType
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+	Offset int
+}
+
+// SearchResult is a message matching a search. Snippet quotes the
+// matching parts of the message as HTML, with matches wrapped in <mark>.
+type SearchResult struct {
+	Message
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+// SearchPage is a page of search results, best match first
+type SearchPage struct {
+	Results    []SearchResult `json:"results"`
+	Total      int            `json:"total"`
+	NextOffset int            `json:"next_offset,omitempty"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// maxStickerIDLength bounds sticker and pack IDs in characters
+const maxStickerIDLength = 64
+
+// StickerPack groups stickers. Disabled packs are hidden from clients and
+// their stickers cannot be sent; packs with a Room are only available
+// there, e.g. during a debate held in that room.
+type StickerPack struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Room        string    `json:"room,omitempty"` // empty makes the pack available in all rooms
+	Enabled     bool      `json:"enabled"`
+	Stickers    []Sticker `json:"stickers"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Sticker is sent as the content of sticker messages by its ID
+type Sticker struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	ImageURL string `json:"image_url"`
+}
+
+// ValidStickerID reports whether id can be used as a sticker or pack ID.
+// IDs follow the rules of room names.
+func ValidStickerID(id string) bool {
+	return len(id) <= maxStickerIDLength && ValidRoom(id)
+}
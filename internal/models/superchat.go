@@ -0,0 +1,66 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// SuperChatInfo describes the payment behind a superchat. Amount is in the
+// currency's minor unit (paise for INR, cents for USD). Tier and
+// PinnedUntil are set by the server from the amount.
+type SuperChatInfo struct {
+	Amount      int64     `json:"amount"`
+	Currency    string    `json:"currency"`
+	Tier        int       `json:"tier"`
+	PinnedUntil time.Time `json:"pinned_until"`
+}
+
+// SuperChatTier sets how long a superchat of at least MinAmount stays
+// pinned at the top of its room
+type SuperChatTier struct {
+	Level       int
+	MinAmount   int64
+	PinDuration time.Duration
+}
+
+// superChatTiers lists the tiers of each supported currency, lowest first
+var superChatTiers = map[string][]SuperChatTier{
+	"INR": {
+		{Level: 1, MinAmount: 2000, PinDuration: time.Minute},
+		{Level: 2, MinAmount: 10000, PinDuration: 2 * time.Minute},
+		{Level: 3, MinAmount: 50000, PinDuration: 5 * time.Minute},
+		{Level: 4, MinAmount: 100000, PinDuration: 15 * time.Minute},
+		{Level: 5, MinAmount: 500000, PinDuration: 30 * time.Minute},
+	},
+	"USD": {
+		{Level: 1, MinAmount: 100, PinDuration: time.Minute},
+		{Level: 2, MinAmount: 200, PinDuration: 2 * time.Minute},
+		{Level: 3, MinAmount: 1000, PinDuration: 5 * time.Minute},
+		{Level: 4, MinAmount: 2000, PinDuration: 15 * time.Minute},
+		{Level: 5, MinAmount: 10000, PinDuration: 30 * time.Minute},
+	},
+}
+
+// SuperChatTierFor returns the tier of a payment. It returns false for
+// unsupported currencies and amounts below the lowest tier.
+func SuperChatTierFor(amount int64, currency string) (SuperChatTier, bool) {
+	var tier SuperChatTier
+	found := false
+	for _, t := range superChatTiers[strings.ToUpper(currency)] {
+		if amount >= t.MinAmount {
+			tier = t
+			found = true
+		}
+	}
+	return tier, found
+}
+
+// SuperChatTotal sums superchats per user or per room and currency
+type SuperChatTotal struct {
+	UserID   string `json:"user_id,omitempty"`
+	Username string `json:"username,omitempty"`
+	Room     string `json:"room,omitempty"`
+	Currency string `json:"currency"`
+	Amount   int64  `json:"amount"`
+	Count    int    `json:"count"`
+}
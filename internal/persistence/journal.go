@@ -0,0 +1,108 @@
+package persistence
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+// Journal is an append-only file of messages that could not be written to
+// the database. Each line holds one JSON-encoded message.
+type Journal struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewJournal(path string) *Journal {
+	return &Journal{path: path}
+}
+
+// Append writes messages to the end of the journal and syncs the file
+func (j *Journal) Append(messages []models.Message) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(j.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create journal directory: %w", err)
+	}
+	f, err := os.OpenFile(j.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, msg := range messages {
+		if err := enc.Encode(msg); err != nil {
+			return fmt.Errorf("failed to encode journal entry: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return f.Sync()
+}
+
+// Replay passes the journaled messages to save in chunks and truncates the
+// journal once all of them have been saved. On error the journal is left
+// untouched and will be replayed again later, so save must be idempotent.
+func (j *Journal) Replay(chunkSize int, save func([]models.Message) error) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	f, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer f.Close()
+
+	var messages []models.Message
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var msg models.Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue // skip a torn last line from a crash mid-write
+		}
+		messages = append(messages, msg)
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	for start := 0; start < len(messages); start += chunkSize {
+		end := start + chunkSize
+		if end > len(messages) {
+			end = len(messages)
+		}
+		if err := save(messages[start:end]); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := os.Truncate(j.path, 0); err != nil {
+		return len(messages), fmt.Errorf("failed to truncate journal: %w", err)
+	}
+	return len(messages), nil
+}
+
+// Size returns the journal size in bytes
+func (j *Journal) Size() int64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	info, err := os.Stat(j.path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
@@ -0,0 +1,246 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+// Store writes batches of messages. SaveBatch must be idempotent since
+// batches are retried and replayed from the journal.
+type Store interface {
+	SaveBatch(messages []models.Message) error
+}
+
+type Options struct {
+	QueueSize     int
+	BatchSize     int
+	FlushInterval time.Duration
+	MaxRetries    int
+	JournalPath   string
+}
+
+const (
+	initialBackoff = 100 * time.Millisecond
+	maxBackoff     = 5 * time.Second
+
+	// journalReplayInterval is how often journaled messages are retried
+	// while the database is recovering
+	journalReplayInterval = 30 * time.Second
+)
+
+// Stats describes the state of the write-behind queue
+type Stats struct {
+	QueueDepth    int   `json:"queue_depth"`
+	QueueCapacity int   `json:"queue_capacity"`
+	Written       int64 `json:"written"`
+	FailedWrites  int64 `json:"failed_writes"`
+	Journaled     int64 `json:"journaled"`
+	JournalBytes  int64 `json:"journal_bytes"`
+}
+
+// Writer persists messages in the background. Messages are queued, written
+// in multi-row batches once BatchSize messages are waiting or FlushInterval
+// has passed, retried with exponential backoff, and spilled to an on-disk
+// journal if the database stays unavailable. The journal is replayed on
+// start and periodically afterwards.
+type Writer struct {
+	store   Store
+	opts    Options
+	queue   chan models.Message
+	journal *Journal
+	done    chan struct{}
+	stopped sync.WaitGroup
+
+	written      atomic.Int64
+	failedWrites atomic.Int64
+	journaled    atomic.Int64
+}
+
+// Defaults used for options that are not set
+const (
+	defaultQueueSize     = 10000
+	defaultBatchSize     = 100
+	defaultFlushInterval = time.Second
+)
+
+func NewWriter(store Store, opts Options) *Writer {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = defaultQueueSize
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultFlushInterval
+	}
+	return &Writer{
+		store:   store,
+		opts:    opts,
+		queue:   make(chan models.Message, opts.QueueSize),
+		journal: NewJournal(opts.JournalPath),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start replays the journal left by a previous run and starts the batching
+// loop.
+func (w *Writer) Start() {
+	w.replayJournal()
+
+	w.stopped.Add(1)
+	go w.run()
+}
+
+// Enqueue queues a message for writing. It never blocks: when the queue is
+// full the message goes straight to the journal.
+func (w *Writer) Enqueue(msg models.Message) {
+	select {
+	case w.queue <- msg:
+	default:
+		w.spill([]models.Message{msg})
+	}
+}
+
+// Close flushes the queue and stops the writer. Whatever cannot be written
+// before ctx expires is journaled.
+func (w *Writer) Close(ctx context.Context) error {
+	close(w.done)
+
+	stopped := make(chan struct{})
+	go func() {
+		w.stopped.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("message queue not flushed: %w", ctx.Err())
+	}
+}
+
+func (w *Writer) Stats() Stats {
+	return Stats{
+		QueueDepth:    len(w.queue),
+		QueueCapacity: cap(w.queue),
+		Written:       w.written.Load(),
+		FailedWrites:  w.failedWrites.Load(),
+		Journaled:     w.journaled.Load(),
+		JournalBytes:  w.journal.Size(),
+	}
+}
+
+func (w *Writer) run() {
+	defer w.stopped.Done()
+
+	flush := time.NewTicker(w.opts.FlushInterval)
+	defer flush.Stop()
+	replay := time.NewTicker(journalReplayInterval)
+	defer replay.Stop()
+
+	batch := make([]models.Message, 0, w.opts.BatchSize)
+	write := func() {
+		if len(batch) == 0 {
+			return
+		}
+		w.writeBatch(batch, true)
+		batch = make([]models.Message, 0, w.opts.BatchSize)
+	}
+
+	for {
+		select {
+		case msg := <-w.queue:
+			batch = append(batch, msg)
+			if len(batch) >= w.opts.BatchSize {
+				write()
+			}
+
+		case <-flush.C:
+			write()
+
+		case <-replay.C:
+			w.replayJournal()
+
+		case <-w.done:
+			// Drain without retrying so shutdown is not held up by
+			// a database outage; failed batches are journaled
+			for {
+				select {
+				case msg := <-w.queue:
+					batch = append(batch, msg)
+					if len(batch) >= w.opts.BatchSize {
+						w.writeBatch(batch, false)
+						batch = batch[:0]
+					}
+				default:
+					if len(batch) > 0 {
+						w.writeBatch(batch, false)
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+// writeBatch saves a batch, retrying with backoff if retry is set, and
+// journals it if every attempt fails.
+func (w *Writer) writeBatch(batch []models.Message, retry bool) {
+	attempts := 1
+	if retry {
+		attempts += w.opts.MaxRetries
+	}
+
+	backoff := initialBackoff
+	for attempt := 1; ; attempt++ {
+		err := w.store.SaveBatch(batch)
+		if err == nil {
+			w.written.Add(int64(len(batch)))
+			return
+		}
+		w.failedWrites.Add(1)
+		slog.Error("failed to save messages", "count", len(batch), "attempt", attempt, "attempts", attempts, "error", err)
+
+		if attempt >= attempts {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+		case <-w.done:
+			attempts = attempt
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+
+	w.spill(batch)
+}
+
+func (w *Writer) spill(messages []models.Message) {
+	if err := w.journal.Append(messages); err != nil {
+		slog.Error("failed to journal messages, they are lost", "count", len(messages), "error", err)
+		return
+	}
+	w.journaled.Add(int64(len(messages)))
+}
+
+func (w *Writer) replayJournal() {
+	n, err := w.journal.Replay(w.opts.BatchSize, w.store.SaveBatch)
+	if err != nil {
+		slog.Error("failed to replay message journal", "error", err)
+		return
+	}
+	if n > 0 {
+		w.written.Add(int64(n))
+		slog.Info("replayed journaled messages", "count", n)
+	}
+}
@@ -0,0 +1,195 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+// failingStore fails the first failures calls to SaveBatch and records the
+// IDs of every batch it saves afterwards
+type failingStore struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+	saved    [][]string
+}
+
+func (s *failingStore) SaveBatch(messages []models.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls++
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("database unavailable")
+	}
+	var ids []string
+	for _, msg := range messages {
+		ids = append(ids, msg.ID)
+	}
+	s.saved = append(s.saved, ids)
+	return nil
+}
+
+func (s *failingStore) savedIDs() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saved
+}
+
+func testMessages(ids ...string) []models.Message {
+	var messages []models.Message
+	for _, id := range ids {
+		messages = append(messages, models.Message{ID: id, Type: models.TextMessage, Content: "message " + id})
+	}
+	return messages
+}
+
+func newTestWriter(t *testing.T, store Store, maxRetries int) *Writer {
+	t.Helper()
+	return NewWriter(store, Options{
+		BatchSize:   2,
+		MaxRetries:  maxRetries,
+		JournalPath: filepath.Join(t.TempDir(), "journal", "messages.jsonl"),
+	})
+}
+
+func TestWriteBatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		failures   int
+		maxRetries int
+		retry      bool
+		wantCalls  int
+		// wantJournaled is whether the batch ends up in the journal
+		wantJournaled bool
+	}{
+		{name: "saved first time", maxRetries: 2, retry: true, wantCalls: 1},
+		{name: "saved on a retry", failures: 2, maxRetries: 2, retry: true, wantCalls: 3},
+		{name: "journaled after the last retry", failures: 3, maxRetries: 2, retry: true, wantCalls: 3, wantJournaled: true},
+		{name: "journaled without retrying", failures: 1, maxRetries: 2, wantCalls: 1, wantJournaled: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &failingStore{failures: tc.failures}
+			w := newTestWriter(t, store, tc.maxRetries)
+
+			w.writeBatch(testMessages("a", "b"), tc.retry)
+
+			if store.calls != tc.wantCalls {
+				t.Errorf("SaveBatch called %d times, want %d", store.calls, tc.wantCalls)
+			}
+			stats := w.Stats()
+			if journaled := stats.Journaled == 2 && stats.JournalBytes > 0; journaled != tc.wantJournaled {
+				t.Errorf("journaled = %v (stats %+v), want %v", journaled, stats, tc.wantJournaled)
+			}
+			if !tc.wantJournaled && stats.Written != 2 {
+				t.Errorf("written = %d, want 2", stats.Written)
+			}
+		})
+	}
+}
+
+func TestJournalReplay(t *testing.T) {
+	// One failure journals the first batch, the next fails the first replay
+	store := &failingStore{failures: 2}
+	w := newTestWriter(t, store, 0)
+
+	w.writeBatch(testMessages("1", "2"), false)
+	w.spill(testMessages("3", "4", "5"))
+
+	// The database is still down: the journal is kept for the next replay
+	if n, err := w.journal.Replay(2, store.SaveBatch); err == nil || n != 0 {
+		t.Fatalf("Replay = %d, %v, want an error", n, err)
+	}
+	if w.journal.Size() == 0 {
+		t.Fatal("journal truncated after a failed replay")
+	}
+
+	n, err := w.journal.Replay(2, store.SaveBatch)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if n != 5 {
+		t.Errorf("replayed %d messages, want 5", n)
+	}
+	want := [][]string{{"1", "2"}, {"3", "4"}, {"5"}}
+	if got := store.savedIDs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("saved batches = %v, want %v", got, want)
+	}
+	if size := w.journal.Size(); size != 0 {
+		t.Errorf("journal is %d bytes after replay, want 0", size)
+	}
+
+	if n, err := w.journal.Replay(2, store.SaveBatch); err != nil || n != 0 {
+		t.Errorf("second Replay = %d, %v, want 0, nil", n, err)
+	}
+}
+
+func TestJournalReplaySkipsTornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.jsonl")
+	journal := NewJournal(path)
+	if err := journal.Append(testMessages("1", "2")); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("open journal: %v", err)
+	}
+	fmt.Fprint(f, `{"id":"3","ty`)
+	f.Close()
+
+	store := &failingStore{}
+	if n, err := journal.Replay(10, store.SaveBatch); err != nil || n != 2 {
+		t.Fatalf("Replay = %d, %v, want 2, nil", n, err)
+	}
+	if got, want := store.savedIDs(), [][]string{{"1", "2"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("saved batches = %v, want %v", got, want)
+	}
+}
+
+func TestWriterReplaysJournalOnStart(t *testing.T) {
+	store := &failingStore{failures: 100}
+	w := newTestWriter(t, store, 0)
+	w.Start()
+	for _, msg := range testMessages("1", "2", "3") {
+		w.Enqueue(msg)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := w.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if stats := w.Stats(); stats.Journaled != 3 {
+		t.Fatalf("journaled %d messages on close, want 3", stats.Journaled)
+	}
+
+	// The next run finds the database back and replays the journal
+	store.failures = 0
+	next := NewWriter(store, w.opts)
+	next.Start()
+	if err := next.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	var ids []string
+	for _, batch := range store.savedIDs() {
+		ids = append(ids, batch...)
+	}
+	if want := []string{"1", "2", "3"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("saved %v, want %v", ids, want)
+	}
+	if size := next.journal.Size(); size != 0 {
+		t.Errorf("journal is %d bytes after replay, want 0", size)
+	}
+}
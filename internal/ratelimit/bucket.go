@@ -0,0 +1,75 @@
+package ratelimit
+
+import "time"
+
+// Bucket is a token bucket: it holds up to burst tokens and refills at rate
+// tokens per second. Each allowed event takes one token. Bucket is not safe
+// for concurrent use.
+type Bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	used   time.Time
+}
+
+func NewBucket(rate float64, burst int) *Bucket {
+	return &Bucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		used:   time.Now(),
+	}
+}
+
+// Allow takes a token if one is available
+func (b *Bucket) Allow(now time.Time) bool {
+	b.refill(now)
+	b.used = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// AllowAll takes a token from each bucket if every one has a token
+// available, and none otherwise, so a bucket that refuses does not drain
+// the others
+func AllowAll(now time.Time, buckets ...*Bucket) bool {
+	allowed := true
+	for _, b := range buckets {
+		b.refill(now)
+		b.used = now
+		if b.tokens < 1 {
+			allowed = false
+		}
+	}
+	if !allowed {
+		return false
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return true
+}
+
+// Idle reports whether the bucket is full and has not been used since
+// cutoff, so it can be dropped without changing behavior
+func (b *Bucket) Idle(now, cutoff time.Time) bool {
+	b.refill(now)
+	return b.tokens >= b.burst && b.used.Before(cutoff)
+}
+
+func (b *Bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens += elapsed * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+}
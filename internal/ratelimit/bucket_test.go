@@ -0,0 +1,113 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestBucket returns a full bucket whose clock starts at start
+func newTestBucket(rate float64, burst int, start time.Time) *Bucket {
+	b := NewBucket(rate, burst)
+	b.last = start
+	b.used = start
+	return b
+}
+
+func TestBucketAllow(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		rate  float64
+		burst int
+		// drain is how many tokens are taken at start
+		drain int
+		after time.Duration
+		// want is how many events are allowed after the wait
+		want int
+	}{
+		{name: "full bucket allows burst", rate: 1, burst: 5, want: 5},
+		{name: "empty bucket allows nothing", rate: 1, burst: 5, drain: 5, want: 0},
+		{name: "refills at rate", rate: 2, burst: 5, drain: 5, after: time.Second, want: 2},
+		{name: "partial token is not enough", rate: 1, burst: 5, drain: 5, after: 900 * time.Millisecond, want: 0},
+		{name: "refill is capped at burst", rate: 10, burst: 3, drain: 3, after: time.Minute, want: 3},
+		{name: "fractional rate", rate: 0.5, burst: 2, drain: 2, after: 4 * time.Second, want: 2},
+		{name: "clock going back adds nothing", rate: 1, burst: 2, drain: 2, after: -time.Hour, want: 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newTestBucket(tc.rate, tc.burst, start)
+			for i := 0; i < tc.drain; i++ {
+				if !b.Allow(start) {
+					t.Fatalf("drain %d refused", i)
+				}
+			}
+
+			now := start.Add(tc.after)
+			got := 0
+			for b.Allow(now) {
+				got++
+				if got > tc.burst {
+					t.Fatalf("allowed more than burst %d", tc.burst)
+				}
+			}
+			if got != tc.want {
+				t.Errorf("allowed %d events, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAllowAll(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		connTokens int
+		userTokens int
+		want       bool
+	}{
+		{name: "both have tokens", connTokens: 2, userTokens: 2, want: true},
+		{name: "user bucket empty", connTokens: 2, userTokens: 0, want: false},
+		{name: "connection bucket empty", connTokens: 0, userTokens: 2, want: false},
+		{name: "both empty", connTokens: 0, userTokens: 0, want: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			conn := newTestBucket(0, 2, start)
+			user := newTestBucket(0, 2, start)
+			conn.tokens = float64(tc.connTokens)
+			user.tokens = float64(tc.userTokens)
+
+			if got := AllowAll(start, conn, user); got != tc.want {
+				t.Fatalf("AllowAll = %v, want %v", got, tc.want)
+			}
+
+			taken := 0
+			if tc.want {
+				taken = 1
+			}
+			if conn.tokens != float64(tc.connTokens-taken) {
+				t.Errorf("connection bucket has %v tokens, want %d", conn.tokens, tc.connTokens-taken)
+			}
+			if user.tokens != float64(tc.userTokens-taken) {
+				t.Errorf("user bucket has %v tokens, want %d", user.tokens, tc.userTokens-taken)
+			}
+		})
+	}
+}
+
+func TestBucketIdle(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := newTestBucket(1, 2, start)
+	b.Allow(start)
+
+	if b.Idle(start.Add(time.Second/2), start.Add(time.Minute)) {
+		t.Error("bucket that is not full is idle")
+	}
+	if !b.Idle(start.Add(2*time.Second), start.Add(time.Second)) {
+		t.Error("full bucket unused since the cutoff is not idle")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/pollz/websocket-server/internal/metrics"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -14,6 +15,7 @@ func Connect(redisURL string) (*redis.Client, error) {
 	}
 
 	client := redis.NewClient(opts)
+	client.AddHook(metrics.RedisHook{})
 
 	// Test connection
 	ctx := context.Background()
@@ -3,8 +3,10 @@ package repository
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/pollz/websocket-server/internal/models"
 )
 
@@ -16,98 +18,364 @@ func NewMessageRepository(db *sql.DB) *MessageRepository {
 	return &MessageRepository{db: db}
 }
 
+// messageColumns selects a message and the preview of the message it replies
+// to. Queries using it select from messageTables and must skip deleted
+// messages themselves.
+const messageColumns = `m.id, m.content, m.type, m.room, COALESCE(m.user_id, ''), COALESCE(m.username, ''),
+	m.created_at, COALESCE(m.reply_to, ''), COALESCE(m.seq, 0), p.id, p.username, p.content`
+
+const messageTables = `chat_messages m
+		LEFT JOIN chat_messages p ON p.id = m.reply_to AND p.deleted_at IS NULL`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// parentColumns receives the reply preview columns of messageColumns
+type parentColumns struct {
+	id, username, content sql.NullString
+}
+
+// attach sets the reply preview of msg if it replies to a message
+func (p *parentColumns) attach(msg *models.Message) {
+	if p.id.Valid {
+		msg.ReplyPreview = models.NewReplyPreview(models.Message{
+			ID:       p.id.String,
+			Username: p.username.String,
+			Content:  p.content.String,
+		})
+	}
+}
+
+// messageDest returns the scan destinations of messageColumns, so queries
+// can select further columns after them
+func messageDest(msg *models.Message, parent *parentColumns) []interface{} {
+	return []interface{}{&msg.ID, &msg.Content, &msg.Type, &msg.Room, &msg.UserID, &msg.Username,
+		&msg.CreatedAt, &msg.ReplyTo, &msg.Seq, &parent.id, &parent.username, &parent.content}
+}
+
+// scanMessage reads a row selected with messageColumns
+func scanMessage(row scanner) (models.Message, error) {
+	var msg models.Message
+	var parent parentColumns
+	if err := row.Scan(messageDest(&msg, &parent)...); err != nil {
+		return msg, err
+	}
+	parent.attach(&msg)
+	return msg, nil
+}
+
+// nullIfEmpty stores empty optional references as NULL
+func nullIfEmpty(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// nullIfZero stores missing sequence numbers as NULL
+func nullIfZero(n int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: n, Valid: n != 0}
+}
+
 func (r *MessageRepository) Save(msg models.Message) error {
 	query := `
-		INSERT INTO chat_messages (id, content, type, user_id, username, created_at) 
-		VALUES ($1, $2, $3, $4, $5, $6)`
-	
-	_, err := r.db.Exec(query, msg.ID, msg.Content, msg.Type, msg.UserID, msg.Username, msg.CreatedAt)
+		INSERT INTO chat_messages (id, content, type, room, user_id, username, created_at, reply_to, seq)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err := r.db.Exec(query, msg.ID, msg.Content, msg.Type, msg.Room, msg.UserID, msg.Username, msg.CreatedAt,
+		nullIfEmpty(msg.ReplyTo), nullIfZero(msg.Seq))
 	if err != nil {
 		return fmt.Errorf("failed to save message: %w", err)
 	}
-	
+
 	return nil
 }
 
-func (r *MessageRepository) GetRecent(limit int) ([]models.Message, error) {
+// SaveBatch inserts messages with a single multi-row statement. Messages
+// that already exist are skipped, so a batch can safely be retried, except
+// that the empty tombstone of a message deleted before it was written is
+// filled in and stays deleted. Messages of a room that was cleared after they were sent are stored
+// deleted.
+func (r *MessageRepository) SaveBatch(messages []models.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	const columns = 9
+	var query strings.Builder
+	query.WriteString("INSERT INTO chat_messages (id, content, type, room, user_id, username, created_at, reply_to, seq) VALUES ")
+	args := make([]interface{}, 0, len(messages)*columns)
+	ids := make([]string, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.ID
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		n := i * columns
+		fmt.Fprintf(&query, "($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9)
+		args = append(args, msg.ID, msg.Content, msg.Type, msg.Room, msg.UserID, msg.Username, msg.CreatedAt,
+			nullIfEmpty(msg.ReplyTo), nullIfZero(msg.Seq))
+	}
+	query.WriteString(` ON CONFLICT (id) DO UPDATE SET
+		content = EXCLUDED.content,
+		type = EXCLUDED.type,
+		user_id = EXCLUDED.user_id,
+		username = EXCLUDED.username,
+		created_at = EXCLUDED.created_at,
+		reply_to = EXCLUDED.reply_to,
+		seq = EXCLUDED.seq
+		WHERE chat_messages.content = '' AND chat_messages.deleted_at IS NOT NULL`)
+
+	if _, err := tx.Exec(query.String(), args...); err != nil {
+		return fmt.Errorf("failed to save messages: %w", err)
+	}
+
+	// Messages without a sequence number are compared by time instead
+	cleared := `
+		UPDATE chat_messages m SET deleted_at = c.cleared_at, deleted_by = c.cleared_by
+		FROM room_clears c
+		WHERE m.id = ANY($1) AND m.room = c.room AND m.deleted_at IS NULL
+			AND (m.seq <= c.seq OR (m.seq IS NULL AND m.created_at <= c.cleared_at))`
+
+	if _, err := tx.Exec(cleared, pq.Array(ids)); err != nil {
+		return fmt.Errorf("failed to delete messages of cleared rooms: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *MessageRepository) GetRecent(room string, limit int) ([]models.Message, error) {
 	query := `
-		SELECT id, content, type, COALESCE(user_id, ''), COALESCE(username, ''), created_at 
-		FROM chat_messages 
-		ORDER BY created_at DESC 
-		LIMIT $1`
-	
-	rows, err := r.db.Query(query, limit)
+		SELECT ` + messageColumns + `
+		FROM ` + messageTables + `
+		WHERE m.room = $1 AND m.deleted_at IS NULL
+		ORDER BY m.created_at DESC
+		LIMIT $2`
+
+	rows, err := r.db.Query(query, room, limit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get recent messages: %w", err)
 	}
 	defer rows.Close()
-	
+
 	var messages []models.Message
 	for rows.Next() {
-		var msg models.Message
-		err := rows.Scan(&msg.ID, &msg.Content, &msg.Type, &msg.UserID, &msg.Username, &msg.CreatedAt)
+		msg, err := scanMessage(rows)
 		if err != nil {
 			continue
 		}
 		messages = append([]models.Message{msg}, messages...) // Prepend to reverse order
 	}
-	
+
 	return messages, nil
 }
 
-func (r *MessageRepository) Search(query string, limit int) ([]models.Message, error) {
-	sqlQuery := `
-		SELECT id, content, type, COALESCE(user_id, ''), COALESCE(username, ''), created_at 
-		FROM chat_messages 
-		WHERE content ILIKE $1
-		ORDER BY created_at DESC 
+// GetPage returns up to limit messages of the room next to cursor, oldest
+// first: the messages before it, or after it if after is set. A nil cursor
+// pages back from the newest message. more reports whether further
+// messages exist in that direction.
+func (r *MessageRepository) GetPage(room string, cursor *models.Cursor, after bool, limit int) ([]models.Message, bool, error) {
+	args := []interface{}{room, limit + 1}
+	where := "m.room = $1 AND m.deleted_at IS NULL"
+	order := "DESC"
+	if cursor != nil {
+		args = append(args, cursor.CreatedAt, cursor.ID)
+		if after {
+			where += " AND (m.created_at, m.id) > ($3, $4)"
+			order = "ASC"
+		} else {
+			where += " AND (m.created_at, m.id) < ($3, $4)"
+		}
+	}
+
+	query := `
+		SELECT ` + messageColumns + `
+		FROM ` + messageTables + `
+		WHERE ` + where + `
+		ORDER BY m.created_at ` + order + `, m.id ` + order + `
 		LIMIT $2`
-	
-	rows, err := r.db.Query(sqlQuery, "%"+query+"%", limit)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get message page: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []models.Message{}
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to scan message: %w", err)
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to get message page: %w", err)
+	}
+
+	more := len(messages) > limit
+	if more {
+		messages = messages[:limit]
+	}
+	if order == "DESC" {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+	return messages, more, nil
+}
+
+// GetBySeq returns the room's stored messages with a sequence number in
+// (after, upto], in order
+func (r *MessageRepository) GetBySeq(room string, after, upto int64) ([]models.Message, error) {
+	query := `
+		SELECT ` + messageColumns + `
+		FROM ` + messageTables + `
+		WHERE m.room = $1 AND m.seq > $2 AND m.seq <= $3 AND m.deleted_at IS NULL
+		ORDER BY m.seq`
+
+	rows, err := r.db.Query(query, room, after, upto)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search messages: %w", err)
+		return nil, fmt.Errorf("failed to get messages by sequence: %w", err)
 	}
 	defer rows.Close()
-	
+
 	var messages []models.Message
 	for rows.Next() {
-		var msg models.Message
-		err := rows.Scan(&msg.ID, &msg.Content, &msg.Type, &msg.UserID, &msg.Username, &msg.CreatedAt)
+		msg, err := scanMessage(rows)
 		if err != nil {
-			continue
+			return nil, fmt.Errorf("failed to scan message: %w", err)
 		}
 		messages = append(messages, msg)
 	}
-	
-	return messages, nil
+
+	return messages, rows.Err()
 }
 
-func (r *MessageRepository) GetByDateRange(start, end time.Time) ([]models.Message, error) {
+// GetByDateRange returns messages created between start and end. An empty
+// room matches all rooms.
+func (r *MessageRepository) GetByDateRange(room string, start, end time.Time) ([]models.Message, error) {
 	query := `
-		SELECT id, content, type, COALESCE(user_id, ''), COALESCE(username, ''), created_at 
-		FROM chat_messages 
-		WHERE created_at BETWEEN $1 AND $2
-		ORDER BY created_at ASC`
-	
-	rows, err := r.db.Query(query, start, end)
+		SELECT ` + messageColumns + `
+		FROM ` + messageTables + `
+		WHERE m.created_at BETWEEN $1 AND $2 AND ($3 = '' OR m.room = $3) AND m.deleted_at IS NULL
+		ORDER BY m.created_at ASC`
+
+	rows, err := r.db.Query(query, start, end, room)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get messages by date range: %w", err)
 	}
 	defer rows.Close()
-	
+
 	var messages []models.Message
 	for rows.Next() {
-		var msg models.Message
-		err := rows.Scan(&msg.ID, &msg.Content, &msg.Type, &msg.UserID, &msg.Username, &msg.CreatedAt)
+		msg, err := scanMessage(rows)
 		if err != nil {
 			continue
 		}
 		messages = append(messages, msg)
 	}
-	
+
 	return messages, nil
 }
 
+// GetByID returns a message. It returns sql.ErrNoRows if there is none.
+func (r *MessageRepository) GetByID(id string) (models.Message, error) {
+	query := `
+		SELECT ` + messageColumns + `
+		FROM ` + messageTables + `
+		WHERE m.id = $1 AND m.deleted_at IS NULL`
+
+	msg, err := scanMessage(r.db.QueryRow(query, id))
+	if err != nil {
+		return msg, fmt.Errorf("failed to get message %s: %w", id, err)
+	}
+	return msg, nil
+}
+
+// GetReplies returns a page of the replies to a message, oldest first, and
+// the total number of replies
+func (r *MessageRepository) GetReplies(id string, limit, offset int) ([]models.Message, int, error) {
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM chat_messages WHERE reply_to = $1 AND deleted_at IS NULL", id).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count replies: %w", err)
+	}
+
+	query := `
+		SELECT ` + messageColumns + `
+		FROM ` + messageTables + `
+		WHERE m.reply_to = $1 AND m.deleted_at IS NULL
+		ORDER BY m.created_at ASC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.Query(query, id, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get replies: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []models.Message{}
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			continue
+		}
+		messages = append(messages, msg)
+	}
+
+	return messages, total, nil
+}
+
+// Tombstone marks a message as deleted by a moderator. The content is kept
+// for review. A message still waiting in the write queue gets an empty
+// tombstone row that the later insert fills in.
+func (r *MessageRepository) Tombstone(id, room, deletedBy string) error {
+	query := `
+		INSERT INTO chat_messages (id, content, room, deleted_at, deleted_by)
+		VALUES ($1, '', $2, NOW(), $3)
+		ON CONFLICT (id) DO UPDATE SET deleted_at = NOW(), deleted_by = $3`
+
+	if _, err := r.db.Exec(query, id, room, deletedBy); err != nil {
+		return fmt.Errorf("failed to delete message: %w", err)
+	}
+	return nil
+}
+
+// TombstoneRoom marks every message in a room up to sequence number seq as
+// deleted and returns how many were. seq is first recorded as the room's
+// clear watermark, so SaveBatch also deletes the messages that were still
+// waiting to be written.
+func (r *MessageRepository) TombstoneRoom(room string, seq int64, deletedBy string) (int64, error) {
+	watermark := `
+		INSERT INTO room_clears (room, seq, cleared_at, cleared_by)
+		VALUES ($1, $2, NOW(), $3)
+		ON CONFLICT (room) DO UPDATE SET
+			seq = EXCLUDED.seq,
+			cleared_at = EXCLUDED.cleared_at,
+			cleared_by = EXCLUDED.cleared_by`
+
+	if _, err := r.db.Exec(watermark, room, seq, deletedBy); err != nil {
+		return 0, fmt.Errorf("failed to record room clear: %w", err)
+	}
+
+	query := `
+		UPDATE chat_messages SET deleted_at = NOW(), deleted_by = $2
+		WHERE room = $1 AND deleted_at IS NULL AND (seq IS NULL OR seq <= $3)`
+
+	result, err := r.db.Exec(query, room, deletedBy, seq)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear room: %w", err)
+	}
+	return result.RowsAffected()
+}
+
 func (r *MessageRepository) DeleteOlderThan(olderThan time.Duration) error {
 	cutoff := time.Now().Add(-olderThan)
 	_, err := r.db.Exec("DELETE FROM chat_messages WHERE created_at < $1", cutoff)
@@ -115,4 +383,4 @@ func (r *MessageRepository) DeleteOlderThan(olderThan time.Duration) error {
 		return fmt.Errorf("failed to delete old messages: %w", err)
 	}
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+type ModerationRepository struct {
+	db *sql.DB
+}
+
+func NewModerationRepository(db *sql.DB) *ModerationRepository {
+	return &ModerationRepository{db: db}
+}
+
+// LogAction records a moderation action in the audit log
+func (r *ModerationRepository) LogAction(action models.ModerationAction) error {
+	query := `
+		INSERT INTO moderation_log (action, moderator_id, user_id, ip, message_id, room, duration_seconds, reason)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := r.db.Exec(query, action.Action, action.ModeratorID, action.UserID, action.IP,
+		action.MessageID, action.Room, action.Duration, action.Reason)
+	if err != nil {
+		return fmt.Errorf("failed to log moderation action: %w", err)
+	}
+
+	return nil
+}
+
+// AddBan stores a ban and returns it with its ID and creation time
+func (r *ModerationRepository) AddBan(ban models.Ban) (models.Ban, error) {
+	query := `
+		INSERT INTO chat_bans (user_id, ip, reason, created_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRow(query, ban.UserID, ban.IP, ban.Reason, ban.CreatedBy, ban.ExpiresAt).
+		Scan(&ban.ID, &ban.CreatedAt)
+	if err != nil {
+		return ban, fmt.Errorf("failed to add ban: %w", err)
+	}
+
+	return ban, nil
+}
+
+// LiftBans ends the active bans on a user ID or IP address and returns how
+// many there were. Empty values match nothing.
+func (r *ModerationRepository) LiftBans(userID, ip string) (int64, error) {
+	query := `
+		UPDATE chat_bans SET lifted_at = NOW()
+		WHERE lifted_at IS NULL
+			AND (expires_at IS NULL OR expires_at > NOW())
+			AND ((user_id <> '' AND user_id = $1) OR (ip <> '' AND ip = $2))`
+
+	result, err := r.db.Exec(query, userID, ip)
+	if err != nil {
+		return 0, fmt.Errorf("failed to lift bans: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// ActiveBans returns the bans that have neither expired nor been lifted
+func (r *ModerationRepository) ActiveBans() ([]models.Ban, error) {
+	query := `
+		SELECT id, user_id, ip, reason, created_by, created_at, expires_at
+		FROM chat_bans
+		WHERE lifted_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY id`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bans: %w", err)
+	}
+	defer rows.Close()
+
+	var bans []models.Ban
+	for rows.Next() {
+		var ban models.Ban
+		err := rows.Scan(&ban.ID, &ban.UserID, &ban.IP, &ban.Reason, &ban.CreatedBy, &ban.CreatedAt, &ban.ExpiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan ban: %w", err)
+		}
+		bans = append(bans, ban)
+	}
+
+	return bans, rows.Err()
+}
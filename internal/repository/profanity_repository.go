@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+type ProfanityRepository struct {
+	db *sql.DB
+}
+
+func NewProfanityRepository(db *sql.DB) *ProfanityRepository {
+	return &ProfanityRepository{db: db}
+}
+
+func (r *ProfanityRepository) ListRules() ([]models.ProfanityRule, error) {
+	query := `
+		SELECT id, word, match_mode, room, is_allowed, created_at
+		FROM profanity_words
+		ORDER BY id`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list profanity rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []models.ProfanityRule
+	for rows.Next() {
+		var rule models.ProfanityRule
+		if err := rows.Scan(&rule.ID, &rule.Word, &rule.Mode, &rule.Room, &rule.Allow, &rule.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan profanity rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+// AddRule stores a rule and returns it with its ID. Adding a rule that
+// already exists returns the existing one.
+func (r *ProfanityRepository) AddRule(rule models.ProfanityRule) (models.ProfanityRule, error) {
+	query := `
+		INSERT INTO profanity_words (word, match_mode, room, is_allowed)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (word, match_mode, room, is_allowed) DO UPDATE SET word = EXCLUDED.word
+		RETURNING id, created_at`
+
+	err := r.db.QueryRow(query, rule.Word, rule.Mode, rule.Room, rule.Allow).Scan(&rule.ID, &rule.CreatedAt)
+	if err != nil {
+		return rule, fmt.Errorf("failed to add profanity rule: %w", err)
+	}
+
+	return rule, nil
+}
+
+// DeleteRule removes a rule. It returns sql.ErrNoRows if the rule does not
+// exist.
+func (r *ProfanityRepository) DeleteRule(id int64) error {
+	res, err := r.db.Exec("DELETE FROM profanity_words WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete profanity rule: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+type ReactionRepository struct {
+	db *sql.DB
+}
+
+func NewReactionRepository(db *sql.DB) *ReactionRepository {
+	return &ReactionRepository{db: db}
+}
+
+// Add records a user's reaction. It returns whether the reaction is new and
+// the emoji's count on the message afterwards.
+func (r *ReactionRepository) Add(messageID, userID, emoji string) (bool, int, error) {
+	// The outer SELECT sees the table as it was before the insert, so the
+	// inserted row is added to the count
+	query := `
+		WITH inserted AS (
+			INSERT INTO message_reactions (message_id, user_id, emoji)
+			VALUES ($1, $2, $3)
+			ON CONFLICT DO NOTHING
+			RETURNING 1
+		)
+		SELECT (SELECT COUNT(*) FROM inserted),
+			(SELECT COUNT(*) FROM message_reactions WHERE message_id = $1 AND emoji = $3)`
+
+	var changed, count int
+	if err := r.db.QueryRow(query, messageID, userID, emoji).Scan(&changed, &count); err != nil {
+		return false, 0, fmt.Errorf("failed to add reaction: %w", err)
+	}
+
+	return changed > 0, count + changed, nil
+}
+
+// Remove deletes a user's reaction. It returns whether the reaction existed
+// and the emoji's count on the message afterwards.
+func (r *ReactionRepository) Remove(messageID, userID, emoji string) (bool, int, error) {
+	query := `
+		WITH deleted AS (
+			DELETE FROM message_reactions
+			WHERE message_id = $1 AND user_id = $2 AND emoji = $3
+			RETURNING 1
+		)
+		SELECT (SELECT COUNT(*) FROM deleted),
+			(SELECT COUNT(*) FROM message_reactions WHERE message_id = $1 AND emoji = $3)`
+
+	var changed, count int
+	if err := r.db.QueryRow(query, messageID, userID, emoji).Scan(&changed, &count); err != nil {
+		return false, 0, fmt.Errorf("failed to remove reaction: %w", err)
+	}
+
+	return changed > 0, count - changed, nil
+}
+
+// Counts returns the reaction counts of the given messages, keyed by
+// message ID. Messages without reactions are left out.
+func (r *ReactionRepository) Counts(messageIDs []string) (map[string]models.ReactionCounts, error) {
+	counts := make(map[string]models.ReactionCounts)
+	if len(messageIDs) == 0 {
+		return counts, nil
+	}
+
+	query := `
+		SELECT message_id, emoji, COUNT(*)
+		FROM message_reactions
+		WHERE message_id = ANY($1)
+		GROUP BY message_id, emoji`
+
+	rows, err := r.db.Query(query, pq.Array(messageIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reaction counts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID, emoji string
+		var count int
+		if err := rows.Scan(&messageID, &emoji, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan reaction count: %w", err)
+		}
+		if counts[messageID] == nil {
+			counts[messageID] = make(models.ReactionCounts)
+		}
+		counts[messageID][emoji] = count
+	}
+
+	return counts, rows.Err()
+}
+
+func (r *ReactionRepository) DeleteOlderThan(olderThan time.Duration) error {
+	cutoff := time.Now().Add(-olderThan)
+	_, err := r.db.Exec("DELETE FROM message_reactions WHERE created_at < $1", cutoff)
+	if err != nil {
+		return fmt.Errorf("failed to delete old reactions: %w", err)
+	}
+	return nil
+}
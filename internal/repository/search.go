@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+// searchHeadline configures the snippets of search results
+const searchHeadline = "StartSel=<mark>, StopSel=</mark>, MaxWords=30, MinWords=10, MaxFragments=2"
+
+// escapedContent is the message content with HTML special characters
+// escaped, so the only markup in snippets is the highlighting
+const escapedContent = `replace(replace(replace(m.content, '&', '&amp;'), '<', '&lt;'), '>', '&gt;')`
+
+// Search returns a page of the messages matching q, best match first.
+// Without search text the newest matching messages are returned.
+func (r *MessageRepository) Search(q models.SearchQuery) (models.SearchPage, error) {
+	tsquery := buildTSQuery(q.Text)
+
+	args := []interface{}{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := []string{"m.deleted_at IS NULL"}
+	rank := "0"
+	snippet := escapedContent
+	order := "m.created_at DESC"
+	if tsquery != "" {
+		query := "to_tsquery('simple', " + arg(tsquery) + ")"
+		conditions = append(conditions, "m.search_vector @@ "+query)
+		rank = "ts_rank_cd(m.search_vector, " + query + ")"
+		snippet = "ts_headline('simple', " + escapedContent + ", " + query + ", '" + searchHeadline + "')"
+		order = "rank DESC, m.created_at DESC"
+	}
+	if q.Room != "" {
+		conditions = append(conditions, "m.room = "+arg(q.Room))
+	}
+	if q.UserID != "" {
+		conditions = append(conditions, "m.user_id = "+arg(q.UserID))
+	}
+	if q.Type != "" {
+		conditions = append(conditions, "m.type = "+arg(q.Type))
+	}
+	if !q.Since.IsZero() {
+		conditions = append(conditions, "m.created_at >= "+arg(q.Since))
+	}
+	if !q.Until.IsZero() {
+		conditions = append(conditions, "m.created_at < "+arg(q.Until))
+	}
+
+	sqlQuery := `
+		SELECT ` + messageColumns + `, ` + rank + ` AS rank, ` + snippet + `, COUNT(*) OVER ()
+		FROM ` + messageTables + `
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY ` + order + `
+		LIMIT ` + arg(q.Limit) + ` OFFSET ` + arg(q.Offset)
+
+	rows, err := r.db.Query(sqlQuery, args...)
+	if err != nil {
+		return models.SearchPage{}, fmt.Errorf("failed to search messages: %w", err)
+	}
+	defer rows.Close()
+
+	page := models.SearchPage{Results: []models.SearchResult{}}
+	for rows.Next() {
+		var result models.SearchResult
+		var parent parentColumns
+		err := rows.Scan(append(messageDest(&result.Message, &parent), &result.Rank, &result.Snippet, &page.Total)...)
+		if err != nil {
+			return models.SearchPage{}, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		parent.attach(&result.Message)
+		page.Results = append(page.Results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return models.SearchPage{}, fmt.Errorf("failed to search messages: %w", err)
+	}
+
+	if next := q.Offset + len(page.Results); next < page.Total {
+		page.NextOffset = next
+	}
+	return page, nil
+}
+
+// buildTSQuery turns search text into a tsquery matching all its terms.
+// "Quoted phrases" must match in order and words ending in * match as
+// prefixes. Everything but letters and digits is dropped, so the result is
+// always valid tsquery syntax.
+func buildTSQuery(text string) string {
+	var terms []string
+	for i, part := range strings.Split(text, `"`) {
+		// Odd parts were inside quotes
+		if i%2 == 1 {
+			if phrase := tsPhrase(part, false); phrase != "" {
+				terms = append(terms, phrase)
+			}
+			continue
+		}
+		for _, word := range strings.Fields(part) {
+			prefix := strings.HasSuffix(word, "*")
+			if phrase := tsPhrase(strings.TrimRight(word, "*"), prefix); phrase != "" {
+				terms = append(terms, phrase)
+			}
+		}
+	}
+	return strings.Join(terms, " & ")
+}
+
+// tsPhrase joins the words of s so they must follow each other. With
+// prefix set the last word matches as a prefix.
+func tsPhrase(s string, prefix bool) string {
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return ""
+	}
+	if prefix {
+		words[len(words)-1] += ":*"
+	}
+	if len(words) == 1 {
+		return words[0]
+	}
+	return "(" + strings.Join(words, " <-> ") + ")"
+}
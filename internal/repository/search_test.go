@@ -0,0 +1,42 @@
+package repository
+
+import "testing"
+
+func TestBuildTSQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "empty", text: "", want: ""},
+		{name: "blank", text: "  \t ", want: ""},
+		{name: "one word", text: "election", want: "election"},
+		{name: "words", text: "exit  poll results", want: "exit & poll & results"},
+		{name: "prefix", text: "elect*", want: "elect:*"},
+		{name: "prefix of a hyphenated word", text: "exit-poll*", want: "(exit <-> poll:*)"},
+		{name: "lone star", text: "*", want: ""},
+		{name: "phrase", text: `"exit poll" results`, want: "(exit <-> poll) & results"},
+		{name: "star inside a phrase is not a prefix", text: `"exit poll*"`, want: "(exit <-> poll)"},
+		{name: "unclosed quote", text: `results "exit poll`, want: "results & (exit <-> poll)"},
+		{name: "empty quotes", text: `"" ""`, want: ""},
+		{name: "quotes only", text: `"`, want: ""},
+		{name: "operators only", text: "& | ! ( ) : *", want: ""},
+		{name: "operators between words", text: "modi & rahul | !kejriwal", want: "modi & rahul & kejriwal"},
+		{name: "operators inside a word", text: "a&b|c", want: "(a <-> b <-> c)"},
+		{name: "weight syntax", text: "poll:A", want: "(poll <-> A)"},
+		{name: "grouping", text: "(modi|rahul)", want: "(modi <-> rahul)"},
+		{name: "tsquery prefix syntax", text: "!elect:*", want: "elect:*"},
+		{name: "apostrophe", text: "rahul's", want: "(rahul <-> s)"},
+		{name: "single quotes", text: "'poll' 'results'", want: "poll & results"},
+		{name: "sql", text: "'); DROP TABLE chat_messages; --", want: "DROP & TABLE & (chat <-> messages)"},
+		{name: "digits", text: "2024 results", want: "2024 & results"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := buildTSQuery(tc.text); got != tc.want {
+				t.Errorf("buildTSQuery(%q) = %q, want %q", tc.text, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+type StickerRepository struct {
+	db *sql.DB
+}
+
+func NewStickerRepository(db *sql.DB) *StickerRepository {
+	return &StickerRepository{db: db}
+}
+
+// ListPacks returns every pack with its stickers, in display order
+func (r *StickerRepository) ListPacks() ([]models.StickerPack, error) {
+	query := `
+		SELECT p.id, p.name, p.description, p.room, p.enabled, p.updated_at,
+			COALESCE(s.id, ''), COALESCE(s.name, ''), COALESCE(s.image_url, '')
+		FROM sticker_packs p
+		LEFT JOIN stickers s ON s.pack_id = p.id
+		ORDER BY p.sort_order, p.id, s.sort_order, s.id`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sticker packs: %w", err)
+	}
+	defer rows.Close()
+
+	var packs []models.StickerPack
+	for rows.Next() {
+		var pack models.StickerPack
+		var sticker models.Sticker
+		err := rows.Scan(&pack.ID, &pack.Name, &pack.Description, &pack.Room, &pack.Enabled, &pack.UpdatedAt,
+			&sticker.ID, &sticker.Name, &sticker.ImageURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sticker: %w", err)
+		}
+
+		if n := len(packs); n == 0 || packs[n-1].ID != pack.ID {
+			pack.Stickers = []models.Sticker{}
+			packs = append(packs, pack)
+		}
+		if sticker.ID != "" {
+			last := &packs[len(packs)-1]
+			last.Stickers = append(last.Stickers, sticker)
+		}
+	}
+
+	return packs, rows.Err()
+}
+
+// SavePack creates or replaces a pack together with its stickers. A
+// sticker ID can only belong to one pack.
+func (r *StickerRepository) SavePack(pack models.StickerPack) (models.StickerPack, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return pack, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO sticker_packs (id, name, description, room, enabled)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO UPDATE SET
+			name = EXCLUDED.name,
+			description = EXCLUDED.description,
+			room = EXCLUDED.room,
+			enabled = EXCLUDED.enabled,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING updated_at`
+
+	err = tx.QueryRow(query, pack.ID, pack.Name, pack.Description, pack.Room, pack.Enabled).Scan(&pack.UpdatedAt)
+	if err != nil {
+		return pack, fmt.Errorf("failed to save sticker pack: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM stickers WHERE pack_id = $1", pack.ID); err != nil {
+		return pack, fmt.Errorf("failed to replace stickers: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO stickers (id, pack_id, name, image_url, sort_order)
+		VALUES ($1, $2, $3, $4, $5)`)
+	if err != nil {
+		return pack, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for i, sticker := range pack.Stickers {
+		if _, err := stmt.Exec(sticker.ID, pack.ID, sticker.Name, sticker.ImageURL, i); err != nil {
+			return pack, fmt.Errorf("failed to save sticker %s: %w", sticker.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return pack, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return pack, nil
+}
+
+// SetPackEnabled enables or disables a pack. It returns sql.ErrNoRows if
+// the pack does not exist.
+func (r *StickerRepository) SetPackEnabled(id string, enabled bool) error {
+	query := `
+		UPDATE sticker_packs
+		SET enabled = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`
+
+	res, err := r.db.Exec(query, id, enabled)
+	if err != nil {
+		return fmt.Errorf("failed to update sticker pack: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeletePack removes a pack and its stickers. It returns sql.ErrNoRows if
+// the pack does not exist.
+func (r *StickerRepository) DeletePack(id string) error {
+	res, err := r.db.Exec("DELETE FROM sticker_packs WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete sticker pack: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/pollz/websocket-server/internal/models"
+)
+
+type SuperChatRepository struct {
+	db *sql.DB
+}
+
+func NewSuperChatRepository(db *sql.DB) *SuperChatRepository {
+	return &SuperChatRepository{db: db}
+}
+
+// Create records a paid superchat message. Each payment can only pay for
+// one superchat: if it already has one, that message's ID is returned and
+// created is false.
+func (r *SuperChatRepository) Create(msg models.Message, paymentID string) (string, bool, error) {
+	query := `
+		INSERT INTO superchats (message_id, payment_id, room, user_id, username, amount, currency, tier, pinned_until)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (payment_id) DO NOTHING
+		RETURNING message_id`
+
+	sc := msg.SuperChat
+	var messageID string
+	err := r.db.QueryRow(query, msg.ID, paymentID, msg.Room, msg.UserID, msg.Username,
+		sc.Amount, sc.Currency, sc.Tier, sc.PinnedUntil).Scan(&messageID)
+	if err == nil {
+		return messageID, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", false, fmt.Errorf("failed to save superchat: %w", err)
+	}
+
+	err = r.db.QueryRow("SELECT message_id FROM superchats WHERE payment_id = $1", paymentID).Scan(&messageID)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to look up superchat: %w", err)
+	}
+	return messageID, false, nil
+}
+
+// TotalsByUser sums superchats per user and currency, largest first. An
+// empty room sums all rooms.
+func (r *SuperChatRepository) TotalsByUser(room string, limit int) ([]models.SuperChatTotal, error) {
+	query := `
+		SELECT user_id, MAX(username), currency, SUM(amount), COUNT(*)
+		FROM superchats
+		WHERE $1 = '' OR room = $1
+		GROUP BY user_id, currency
+		ORDER BY SUM(amount) DESC
+		LIMIT $2`
+
+	rows, err := r.db.Query(query, room, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get superchat totals: %w", err)
+	}
+	defer rows.Close()
+
+	totals := []models.SuperChatTotal{}
+	for rows.Next() {
+		var t models.SuperChatTotal
+		if err := rows.Scan(&t.UserID, &t.Username, &t.Currency, &t.Amount, &t.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan superchat total: %w", err)
+		}
+		totals = append(totals, t)
+	}
+
+	return totals, rows.Err()
+}
+
+// TotalsByRoom sums superchats per room and currency, largest first
+func (r *SuperChatRepository) TotalsByRoom(limit int) ([]models.SuperChatTotal, error) {
+	query := `
+		SELECT room, currency, SUM(amount), COUNT(*)
+		FROM superchats
+		GROUP BY room, currency
+		ORDER BY SUM(amount) DESC
+		LIMIT $1`
+
+	rows, err := r.db.Query(query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get superchat totals: %w", err)
+	}
+	defer rows.Close()
+
+	totals := []models.SuperChatTotal{}
+	for rows.Next() {
+		var t models.SuperChatTotal
+		if err := rows.Scan(&t.Room, &t.Currency, &t.Amount, &t.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan superchat total: %w", err)
+		}
+		totals = append(totals, t)
+	}
+
+	return totals, rows.Err()
+}
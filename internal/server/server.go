@@ -1,45 +1,78 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
 	"github.com/pollz/websocket-server/internal/config"
 	"github.com/pollz/websocket-server/internal/handlers"
+	"github.com/pollz/websocket-server/internal/metrics"
 	"github.com/pollz/websocket-server/internal/middleware"
 	"github.com/rs/cors"
 )
 
 type Server struct {
-	config    *config.Config
-	wsHandler *handlers.WebSocketHandler
-	apiHandler *handlers.APIHandler
+	httpServer      *http.Server
+	config          *config.Config
+	wsHandler       *handlers.WebSocketHandler
+	apiHandler      *handlers.APIHandler
+	adminHandler    *handlers.AdminHandler
+	internalHandler *handlers.InternalHandler
 }
 
-func New(cfg *config.Config, wsHandler *handlers.WebSocketHandler, apiHandler *handlers.APIHandler) *Server {
+func New(cfg *config.Config, wsHandler *handlers.WebSocketHandler, apiHandler *handlers.APIHandler, adminHandler *handlers.AdminHandler, internalHandler *handlers.InternalHandler) *Server {
 	return &Server{
-		config:     cfg,
-		wsHandler:  wsHandler,
-		apiHandler: apiHandler,
+		httpServer:      &http.Server{Addr: fmt.Sprintf(":%s", cfg.Port)},
+		config:          cfg,
+		wsHandler:       wsHandler,
+		apiHandler:      apiHandler,
+		adminHandler:    adminHandler,
+		internalHandler: internalHandler,
 	}
 }
 
 func (s *Server) Start() error {
 	mux := http.NewServeMux()
-	
-	// WebSocket endpoints
-	mux.HandleFunc("/ws/chat/live", s.wsHandler.HandleConnection)
-	
+
+	// WebSocket endpoints - /ws/chat/{room}, e.g. /ws/chat/live
+	mux.HandleFunc("/ws/chat/", s.wsHandler.HandleConnection)
+
 	// API endpoints - Keep read-only endpoints for existing messages
+	mux.HandleFunc("/api/messages", s.apiHandler.GetMessages)
 	mux.HandleFunc("/api/messages/search", s.apiHandler.SearchMessages)
 	mux.HandleFunc("/api/messages/date", s.apiHandler.GetMessagesByDate)
+	mux.HandleFunc("/api/messages/thread", s.apiHandler.GetThread)
+	mux.HandleFunc("/api/stickers", s.apiHandler.GetStickers)
 	mux.HandleFunc("/api/stats", s.apiHandler.GetStats)
 	mux.HandleFunc("/health", s.apiHandler.HealthCheck)
-	
-	// Apply middleware
-	handler := middleware.Logging(mux)
-	handler = middleware.Recovery(handler)
-	
+	mux.HandleFunc("/metrics", metrics.Authorize(s.config.MetricsToken, metrics.Handler))
+
+	// Admin endpoints - require the admin API key or an admin token
+	admin := s.adminHandler.Authorize
+	mux.HandleFunc("/api/admin/profanity", admin(s.adminHandler.ProfanityRules))
+	mux.HandleFunc("/api/admin/profanity/reload", admin(s.adminHandler.ReloadProfanity))
+	mux.HandleFunc("/api/admin/clients", admin(s.adminHandler.Clients))
+	mux.HandleFunc("/api/admin/bans", admin(s.adminHandler.Bans))
+	mux.HandleFunc("/api/admin/mutes", admin(s.adminHandler.Mutes))
+	mux.HandleFunc("/api/admin/rooms/slowmode", admin(s.adminHandler.SlowMode))
+	mux.HandleFunc("/api/admin/rooms/announce", admin(s.adminHandler.Announce))
+	mux.HandleFunc("/api/admin/rooms/clear", admin(s.adminHandler.ClearRoom))
+	mux.HandleFunc("/api/admin/rooms/pin", admin(s.adminHandler.PinMessage))
+	mux.HandleFunc("/api/admin/superchats/totals", admin(s.adminHandler.SuperChatTotals))
+	mux.HandleFunc("/api/admin/stickers", admin(s.adminHandler.StickerPacks))
+	mux.HandleFunc("/api/admin/stickers/enable", admin(s.adminHandler.EnableStickerPack))
+
+	// Internal endpoints - called by the Pollz backend
+	internal := s.internalHandler.Authorize
+	mux.HandleFunc("/api/internal/polls", internal(s.internalHandler.PollTally))
+	mux.HandleFunc("/api/internal/events", s.internalHandler.VerifySignature(s.internalHandler.Events))
+
+	// Apply middleware. Logging is outermost so panics recovered below are
+	// logged with the request ID.
+	handler := middleware.Recovery(mux)
+	handler = middleware.Logging(handler)
+
 	// Setup CORS
 	c := cors.New(cors.Options{
 		AllowedOrigins:   s.config.AllowedOrigins,
@@ -47,9 +80,15 @@ func (s *Server) Start() error {
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"*"},
 	})
-	
+
 	handler = c.Handler(handler)
-	
-	addr := fmt.Sprintf(":%s", s.config.Port)
-	return http.ListenAndServe(addr, handler)
-}
\ No newline at end of file
+
+	s.httpServer.Handler = handler
+	return s.httpServer.ListenAndServe()
+}
+
+// Shutdown stops accepting new connections and waits for in-flight HTTP
+// requests. Upgraded WebSocket connections are closed by the hub.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
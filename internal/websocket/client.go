@@ -1,7 +1,7 @@
 package websocket
 
 import (
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/google/uuid"
@@ -19,10 +19,34 @@ const (
 	// Send pings to peer with this period (must be less than pongWait)
 	pingPeriod = (pongWait * 9) / 10
 
-	// Maximum message size allowed from peer
-	maxMessageSize = 512 * 1024 // 512KB
+	// Maximum message size allowed from peer. The largest frames the
+	// schemas accept are a few KB; bigger ones close the connection.
+	maxMessageSize = 16 * 1024 // 16KB
+
+	// Frames smaller than this are sent uncompressed even when
+	// permessage-deflate was negotiated, as deflate barely shrinks them
+	compressionThreshold = 256
 )
 
+// Options describe the connection's authenticated user and initial room
+type Options struct {
+	UserID   string
+	Username string
+	Room     string
+	ReadOnly bool
+	Role     models.Role
+	IP       string
+	Polls    []string
+	LastSeq  int64
+
+	// Backpressure is the policy the client asked for, if any
+	Backpressure models.BackpressurePolicy
+
+	// Logger carries the upgrade request's ID; it defaults to slog's
+	// default logger
+	Logger *slog.Logger
+}
+
 type Client struct {
 	ID       string
 	hub      models.Hub
@@ -31,23 +55,26 @@ type Client struct {
 	userID   string
 	username string
 	joinedAt time.Time
+	client   *models.Client
+	codec    codec
 }
 
-func NewClient(hub models.Hub, conn *websocket.Conn, userID, username string) *Client {
-	return &Client{
+func NewClient(hub models.Hub, conn *websocket.Conn, opts Options) *Client {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	c := &Client{
 		ID:       uuid.New().String(),
 		hub:      hub,
 		conn:     conn,
 		send:     make(chan models.Message, 256),
-		userID:   userID,
-		username: username,
+		userID:   opts.UserID,
+		username: opts.Username,
 		joinedAt: time.Now(),
+		codec:    newCodec(conn.Subprotocol()),
 	}
-}
-
-// GetClient returns the models.Client representation
-func (c *Client) GetClient() *models.Client {
-	return &models.Client{
+	c.client = &models.Client{
 		ID:       c.ID,
 		Hub:      c.hub,
 		Conn:     c.conn,
@@ -55,7 +82,23 @@ func (c *Client) GetClient() *models.Client {
 		UserID:   c.userID,
 		Username: c.username,
 		JoinedAt: c.joinedAt,
+		Room:     opts.Room,
+		ReadOnly: opts.ReadOnly,
+		Role:     opts.Role,
+		IP:       opts.IP,
+		Polls:    opts.Polls,
+		LastSeq:  opts.LastSeq,
+
+		Backpressure: opts.Backpressure,
 	}
+	c.client.Log = logger.With("conn_id", c.ID)
+	return c
+}
+
+// GetClient returns the models.Client representation. The same pointer is
+// returned on every call since the hub keys its client maps by it.
+func (c *Client) GetClient() *models.Client {
+	return c.client
 }
 
 // ReadPump pumps messages from the websocket connection to the hub
@@ -73,15 +116,20 @@ func (c *Client) ReadPump() {
 	})
 
 	for {
-		var msg models.Message
-		err := c.conn.ReadJSON(&msg)
+		_, data, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("websocket error: %v", err)
+				c.client.Log.Warn("websocket read error", "error", err)
 			}
 			break
 		}
 
+		var msg models.Message
+		if err := c.codec.decode(data, &msg); err != nil {
+			c.hub.Reject(c.client, models.ErrCodeInvalidFrame, "Frames must be chat messages encoded as "+c.codec.name())
+			continue
+		}
+
 		// Set default message type if not specified
 		if msg.Type == "" {
 			msg.Type = models.TextMessage
@@ -92,7 +140,7 @@ func (c *Client) ReadPump() {
 		msg.Username = c.username
 		msg.CreatedAt = time.Now()
 
-		c.hub.Broadcast(msg)
+		c.hub.Receive(c.client, msg)
 	}
 }
 
@@ -110,11 +158,21 @@ func (c *Client) WritePump() {
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
 				// The hub closed the channel
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				var payload []byte
+				if c.client.CloseCode != 0 {
+					payload = websocket.FormatCloseMessage(c.client.CloseCode, c.client.CloseReason)
+				}
+				c.conn.WriteMessage(websocket.CloseMessage, payload)
 				return
 			}
 
-			if err := c.conn.WriteJSON(message); err != nil {
+			data, err := c.codec.encode(message)
+			if err != nil {
+				c.client.Log.Error("failed to encode frame", "type", message.Type, "error", err)
+				continue
+			}
+			c.conn.EnableWriteCompression(len(data) >= compressionThreshold)
+			if err := c.conn.WriteMessage(c.codec.frameType(), data); err != nil {
 				return
 			}
 
@@ -132,4 +190,4 @@ func (c *Client) Start() {
 	c.hub.Register(c.GetClient())
 	go c.WritePump()
 	go c.ReadPump()
-}
\ No newline at end of file
+}
@@ -0,0 +1,101 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/gorilla/websocket"
+	"github.com/pollz/websocket-server/internal/models"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Subprotocols clients may request with Sec-WebSocket-Protocol. Clients
+// that request none use JSON.
+const (
+	ProtocolJSON    = "pollz.json"
+	ProtocolMsgpack = "pollz.msgpack"
+)
+
+// Subprotocols lists the supported subprotocols in order of preference
+var Subprotocols = []string{ProtocolMsgpack, ProtocolJSON}
+
+// codec encodes and decodes the frames of a connection in the format
+// negotiated for it. encode is only called from the write pump and decode
+// from the read pump.
+type codec interface {
+	// name describes the format in error frames
+	name() string
+
+	// frameType is the websocket message type frames are sent as
+	frameType() int
+
+	// encode returns the encoded message, which is only valid until the
+	// next call
+	encode(message models.Message) ([]byte, error)
+
+	decode(data []byte, message *models.Message) error
+}
+
+// newCodec returns the codec of the negotiated subprotocol
+func newCodec(subprotocol string) codec {
+	if subprotocol == ProtocolMsgpack {
+		return newMsgpackCodec()
+	}
+	return jsonCodec{}
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) name() string {
+	return "JSON"
+}
+
+func (jsonCodec) frameType() int {
+	return websocket.TextMessage
+}
+
+func (jsonCodec) encode(message models.Message) ([]byte, error) {
+	return json.Marshal(message)
+}
+
+func (jsonCodec) decode(data []byte, message *models.Message) error {
+	return json.Unmarshal(data, message)
+}
+
+// msgpackCodec sends frames as binary MessagePack maps keyed by the same
+// field names as JSON frames
+type msgpackCodec struct {
+	buf bytes.Buffer
+	enc *msgpack.Encoder
+	dec *msgpack.Decoder
+}
+
+func newMsgpackCodec() *msgpackCodec {
+	c := &msgpackCodec{}
+	c.enc = msgpack.NewEncoder(&c.buf)
+	c.enc.SetCustomStructTag("json")
+	c.dec = msgpack.NewDecoder(nil)
+	c.dec.SetCustomStructTag("json")
+	return c
+}
+
+func (c *msgpackCodec) name() string {
+	return "MessagePack"
+}
+
+func (c *msgpackCodec) frameType() int {
+	return websocket.BinaryMessage
+}
+
+func (c *msgpackCodec) encode(message models.Message) ([]byte, error) {
+	c.buf.Reset()
+	if err := c.enc.Encode(&message); err != nil {
+		return nil, err
+	}
+	return c.buf.Bytes(), nil
+}
+
+func (c *msgpackCodec) decode(data []byte, message *models.Message) error {
+	c.dec.ResetReader(bytes.NewReader(data))
+	return c.dec.Decode(message)
+}